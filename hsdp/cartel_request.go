@@ -0,0 +1,110 @@
+package hsdp
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// The resize endpoints are not covered by go-hsdp-api (see its cartel package for the
+// endpoints it does cover) and could not be verified against published Cartel API docs.
+// When a Cartel deployment does not offer them, cartelRequest reports ErrCartelEndpointUnsupported
+const (
+	cartelChangeInstanceTypePath = "v3/api/change_instance_type"
+	cartelModifyVolumesPath      = "v3/api/modify_volumes"
+)
+
+// ErrCartelEndpointUnsupported is returned when Cartel does not know the requested endpoint
+var ErrCartelEndpointUnsupported = errors.New("endpoint not supported by this Cartel deployment")
+
+// cartelResponse is the generic envelope returned by Cartel
+type cartelResponse struct {
+	Message     json.RawMessage `json:"message,omitempty"`
+	Code        int             `json:"code,omitempty"`
+	Description string          `json:"description,omitempty"`
+}
+
+// cartelRequest performs a call against a Cartel endpoint which is not (yet)
+// covered by go-hsdp-api. The body is signed with the Cartel secret the same
+// way go-hsdp-api does it. The token and, when given, name-tag are added to the body. The
+// HTTP client of the configured Cartel client is reused, including its debug logging
+func cartelRequest(config *Config, path, nameTag string, body map[string]interface{}) (*cartelResponse, *http.Response, error) {
+	if config.CartelHost == "" {
+		return nil, nil, fmt.Errorf("missing Cartel host")
+	}
+	scheme := "https"
+	if config.CartelNoTLS {
+		scheme = "http"
+	}
+	payload := map[string]interface{}{}
+	for k, v := range body {
+		payload[k] = v
+	}
+	payload["token"] = config.CartelToken
+	if nameTag != "" {
+		payload["name-tag"] = []string{nameTag}
+	}
+	bodyBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	endpoint := fmt.Sprintf("%s://%s/%s", scheme, strings.TrimSuffix(config.CartelHost, "/"), path)
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(bodyBytes))
+	if err != nil {
+		return nil, nil, err
+	}
+	signature := hmac.New(sha256.New, []byte(config.CartelSecret))
+	_, _ = signature.Write(bodyBytes)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Authorization", base64.StdEncoding.EncodeToString(signature.Sum(nil)))
+
+	if _, err := config.CartelClient(); err != nil {
+		return nil, nil, err
+	}
+	httpClient := config.cartelHTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp, err
+	}
+	var result cartelResponse
+	_ = json.Unmarshal(data, &result)
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusMethodNotAllowed {
+		return &result, resp, fmt.Errorf("cartel %s: %w", path, ErrCartelEndpointUnsupported)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := result.Description
+		if message == "" {
+			message = strings.TrimSpace(string(data))
+		}
+		return &result, resp, fmt.Errorf("cartel %s: %d %s", path, resp.StatusCode, message)
+	}
+	return &result, resp, nil
+}
+
+// cartelEndpointSupported probes an endpoint with a request without a name-tag, which does not
+// address any instance. Only a missing endpoint is reported, other errors are expected for a
+// request without instances
+func cartelEndpointSupported(config *Config, path string) error {
+	_, resp, err := cartelRequest(config, path, "", nil)
+	if resp == nil || errors.Is(err, ErrCartelEndpointUnsupported) {
+		return err
+	}
+	return nil
+}
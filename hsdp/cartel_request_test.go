@@ -0,0 +1,82 @@
+package hsdp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCartelRequest(t *testing.T) {
+	var path, signature string
+	var body map[string]interface{}
+	var raw []byte
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		signature = r.Header.Get("Authorization")
+		raw, _ = ioutil.ReadAll(r.Body)
+		body = nil
+		_ = json.Unmarshal(raw, &body)
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(path, "unknown") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if strings.HasSuffix(path, "modify_volumes") {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"code":400,"description":"volumes cannot shrink"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"code":0,"description":"ok"}`))
+	}))
+	defer server.Close()
+
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	result, resp, err := cartelRequest(config, cartelChangeInstanceTypePath, "host", map[string]interface{}{
+		"instance_type": "m5.xlarge",
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "ok", result.Description)
+	assert.Equal(t, "/"+cartelChangeInstanceTypePath, path)
+	assert.Equal(t, "m5.xlarge", body["instance_type"])
+	assert.Equal(t, "token", body["token"])
+	assert.Equal(t, []interface{}{"host"}, body["name-tag"])
+
+	mac := hmac.New(sha256.New, []byte("secret"))
+	_, _ = mac.Write(raw)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(mac.Sum(nil)), signature)
+
+	_, resp, err = cartelRequest(config, cartelModifyVolumesPath, "host", nil)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "volumes cannot shrink")
+	}
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+
+	_, _, err = cartelRequest(config, "v3/api/unknown", "host", nil)
+	assert.True(t, errors.Is(err, ErrCartelEndpointUnsupported))
+
+	// Probes address no instance, only a missing endpoint is an error
+	assert.Nil(t, cartelEndpointSupported(config, cartelModifyVolumesPath))
+	_, hasNameTag := body["name-tag"]
+	assert.False(t, hasNameTag)
+	assert.True(t, errors.Is(cartelEndpointSupported(config, "v3/api/unknown"), ErrCartelEndpointUnsupported))
+}
@@ -1,9 +1,11 @@
 package hsdp
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/google/fhir/go/jsonformat"
 	"github.com/hashicorp/go-retryablehttp"
@@ -46,6 +48,7 @@ type Config struct {
 
 	iamClient             *iam.Client
 	cartelClient          *cartel.Client
+	cartelHTTPClient      *http.Client
 	s3credsClient         *s3creds.Client
 	consoleClient         *console.Client
 	pkiClient             *pki.Client
@@ -61,6 +64,12 @@ type Config struct {
 	notificationClientErr error
 	TimeZone              string
 
+	permissionCatalog   []string
+	permissionCatalogMu sync.Mutex
+
+	securityGroups   []string
+	securityGroupsMu sync.Mutex
+
 	ma *jsonformat.Marshaller
 	um *jsonformat.Unmarshaller
 }
@@ -116,6 +125,64 @@ func (c *Config) NotificationClient() (*notification.Client, error) {
 	return c.notificationClient, c.notificationClientErr
 }
 
+// IAMPermissionCatalog returns the names of all IAM permissions. The catalog is
+// cached for the lifetime of the provider instance once it was fetched successfully,
+// failures are retried on the next call
+func (c *Config) IAMPermissionCatalog() ([]string, error) {
+	c.permissionCatalogMu.Lock()
+	defer c.permissionCatalogMu.Unlock()
+	if c.permissionCatalog != nil {
+		return c.permissionCatalog, nil
+	}
+	client, err := c.IAMClient()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrMissingIAMCredentials
+	}
+	permissions, _, err := client.Permissions.GetPermissions(nil) // Get all permissions
+	if err != nil {
+		return nil, err
+	}
+	if permissions == nil {
+		return nil, ErrInvalidResponse
+	}
+	catalog := make([]string, 0, len(*permissions))
+	for _, p := range *permissions {
+		catalog = append(catalog, p.Name)
+	}
+	c.permissionCatalog = catalog
+	return c.permissionCatalog, nil
+}
+
+// CartelSecurityGroups returns the names of the Cartel security groups. The list is
+// cached for the lifetime of the provider instance once it was fetched successfully,
+// failures are retried on the next call
+func (c *Config) CartelSecurityGroups() ([]string, error) {
+	c.securityGroupsMu.Lock()
+	defer c.securityGroupsMu.Unlock()
+	if c.securityGroups != nil {
+		return c.securityGroups, nil
+	}
+	client, err := c.CartelClient()
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		return nil, ErrMissingCartelCredentials
+	}
+	groups, _, err := client.GetSecurityGroups()
+	if err != nil {
+		return nil, err
+	}
+	if groups == nil {
+		return nil, ErrInvalidResponse
+	}
+	c.securityGroups = append(make([]string, 0, len(*groups)), *groups...)
+	return c.securityGroups, nil
+}
+
 // setupIAMClient sets up an HSDP IAM client
 func (c *Config) setupIAMClient() {
 	var standardClient *http.Client
@@ -252,7 +319,15 @@ func (c *Config) setupCartelClient() {
 			}
 		}
 	}
-	client, err := cartel.NewClient(nil, &cartel.Config{
+	// The HTTP client is kept for the Cartel calls go-hsdp-api does not cover yet,
+	// go-hsdp-api adds the debug logging to its transport
+	c.cartelHTTPClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: c.CartelSkipVerify},
+		},
+	}
+	client, err := cartel.NewClient(c.cartelHTTPClient, &cartel.Config{
 		Region:     c.Region,
 		Host:       c.CartelHost,
 		Token:      c.CartelToken,
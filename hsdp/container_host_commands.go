@@ -0,0 +1,186 @@
+package hsdp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	commandResultsField    = "command_results"
+	defaultCommandTimeout  = 300
+	commandExitCodeUnknown = -1
+
+	// maxCommandOutput limits the stdout and stderr kept in command_results, the full
+	// output is streamed to the Terraform log
+	maxCommandOutput = 4096
+)
+
+// commandResult holds the outcome of a single remote command
+type commandResult struct {
+	Command  string
+	ExitCode int
+	Stdout   string
+	Stderr   string
+	TimedOut bool
+}
+
+// commandOptions controls how a list of commands is executed
+type commandOptions struct {
+	Timeouts        []time.Duration
+	ContinueOnError bool
+	// Env is prepended to each command, e.g. to export variables. It is not part of the results
+	Env string
+}
+
+// lineLogger writes each complete line to the Terraform log, so output of long running
+// commands shows up during apply
+type lineLogger struct {
+	mu     sync.Mutex
+	prefix string
+	buf    bytes.Buffer
+}
+
+func (l *lineLogger) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf.Write(p)
+	for {
+		line, err := l.buf.ReadString('\n')
+		if err != nil {
+			// Keep the incomplete line for the next write
+			l.buf.Reset()
+			l.buf.WriteString(line)
+			return len(p), nil
+		}
+		log.Printf("[INFO] %s %s", l.prefix, strings.TrimRight(line, "\r\n"))
+	}
+}
+
+// Flush logs any remaining partial line
+func (l *lineLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.buf.Len() > 0 {
+		log.Printf("[INFO] %s %s", l.prefix, l.buf.String())
+		l.buf.Reset()
+	}
+}
+
+// exitCode returns the exit status of a finished command, or -1 when it is not known
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	if exitErr, ok := err.(*ssh.ExitError); ok {
+		return exitErr.ExitStatus()
+	}
+	return commandExitCodeUnknown
+}
+
+// expandCommandTimeouts returns the timeout of each command. Entries of the field, e.g.
+// command_timeouts, which are missing or zero fall back to command_timeout
+func expandCommandTimeouts(d *schema.ResourceData, field string, count int) []time.Duration {
+	defaultTimeout := d.Get("command_timeout").(int)
+	if defaultTimeout <= 0 {
+		defaultTimeout = defaultCommandTimeout
+	}
+	overrides := d.Get(field).([]interface{})
+	timeouts := make([]time.Duration, count)
+	for i := range timeouts {
+		timeout := defaultTimeout
+		if i < len(overrides) {
+			if v, ok := overrides[i].(int); ok && v > 0 {
+				timeout = v
+			}
+		}
+		timeouts[i] = time.Duration(timeout) * time.Second
+	}
+	return timeouts
+}
+
+// runCommands executes the commands in order and streams their output to the log. It stops
+// at the first failing command, unless ContinueOnError is set in which case failures are reported as warnings
+func runCommands(ssh *sshConfig, config *Config, commands []string, opts commandOptions) ([]commandResult, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	results := make([]commandResult, 0, len(commands))
+
+	for i, command := range commands {
+		timeout := time.Duration(defaultCommandTimeout) * time.Second
+		if i < len(opts.Timeouts) {
+			timeout = opts.Timeouts[i]
+		}
+		var stdout, stderr bytes.Buffer
+		outLog := &lineLogger{prefix: fmt.Sprintf("[%s] command[%d] stdout:", ssh.Server, i)}
+		errLog := &lineLogger{prefix: fmt.Sprintf("[%s] command[%d] stderr:", ssh.Server, i)}
+		log.Printf("[INFO] [%s] command[%d] running: %s", ssh.Server, i, command)
+		done, err := ssh.Stream(opts.Env+command, timeout,
+			io.MultiWriter(&stdout, outLog), io.MultiWriter(&stderr, errLog))
+		outLog.Flush()
+		errLog.Flush()
+
+		result := commandResult{
+			Command:  command,
+			ExitCode: exitCode(err),
+			Stdout:   stdout.String(),
+			Stderr:   stderr.String(),
+			TimedOut: !done,
+		}
+		results = append(results, result)
+		_, _ = config.Debug("command: %s\ndone: %t\nexit code: %d\nstdout:\n%s\nstderr:\n%s\n",
+			command, done, result.ExitCode, result.Stdout, result.Stderr)
+		if err == nil {
+			continue
+		}
+		_, _ = config.Debug("error: %v\n", err)
+		if !opts.ContinueOnError {
+			return results, append(diags, diag.FromErr(fmt.Errorf("command [%s]: %w", command, err))...)
+		}
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "command failed",
+			Detail:   fmt.Sprintf("command [%s] failed with exit code %d: %v\n%s", command, result.ExitCode, err, result.Stderr),
+		})
+	}
+	return results, diags
+}
+
+// truncateOutput keeps the last maxCommandOutput bytes of output, where errors usually are
+func truncateOutput(output string) string {
+	if len(output) <= maxCommandOutput {
+		return output
+	}
+	return fmt.Sprintf("[%d bytes truncated]\n%s", len(output)-maxCommandOutput, output[len(output)-maxCommandOutput:])
+}
+
+// flattenCommandResults converts the results to the command_results attribute. Output
+// is truncated, so the state does not grow with every verbose command
+func flattenCommandResults(results []commandResult) []map[string]interface{} {
+	flattened := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		flattened = append(flattened, map[string]interface{}{
+			"command":   r.Command,
+			"exit_code": r.ExitCode,
+			"stdout":    truncateOutput(r.Stdout),
+			"stderr":    truncateOutput(r.Stderr),
+			"timed_out": r.TimedOut,
+		})
+	}
+	return flattened
+}
+
+// lastStdout returns the output of the last command which ran
+func lastStdout(results []commandResult) string {
+	if len(results) == 0 {
+		return ""
+	}
+	return results[len(results)-1].Stdout
+}
@@ -0,0 +1,71 @@
+package hsdp
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCommands(t *testing.T) {
+	addr, fingerprint, stop := startTestSSHServer(t, nil)
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+	cfg := &sshConfig{User: "test", Server: host, Port: port, Fingerprint: fingerprint}
+	config := &Config{}
+
+	commands := []string{"first", "exit 3", "last"}
+	opts := commandOptions{Timeouts: []time.Duration{5 * time.Second, 5 * time.Second, 5 * time.Second}}
+
+	results, diags := runCommands(cfg, config, commands, opts)
+	assert.True(t, diags.HasError())
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, 0, results[0].ExitCode)
+		assert.Equal(t, "out: first\n", results[0].Stdout)
+		assert.Equal(t, "err: first\n", results[0].Stderr)
+		assert.Equal(t, 3, results[1].ExitCode)
+		assert.False(t, results[1].TimedOut)
+	}
+
+	opts.ContinueOnError = true
+	results, diags = runCommands(cfg, config, commands, opts)
+	assert.False(t, diags.HasError())
+	assert.Len(t, diags, 1)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "out: last\n", lastStdout(results))
+	}
+
+	// The environment is sent along but not recorded
+	opts.Env = "export A=1; "
+	results, _ = runCommands(cfg, config, []string{"env"}, opts)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "env", results[0].Command)
+		assert.Equal(t, "out: export A=1; env\n", results[0].Stdout)
+	}
+}
+
+func TestFlattenCommandResultsTruncates(t *testing.T) {
+	long := strings.Repeat("a", maxCommandOutput) + "tail"
+	flattened := flattenCommandResults([]commandResult{{Command: "verbose", Stdout: long, Stderr: "short"}})
+	if assert.Len(t, flattened, 1) {
+		stdout := flattened[0]["stdout"].(string)
+		assert.True(t, strings.HasPrefix(stdout, "[4 bytes truncated]\n"))
+		assert.True(t, strings.HasSuffix(stdout, "tail"))
+		assert.Equal(t, "short", flattened[0]["stderr"])
+	}
+}
+
+func TestExpandCommandTimeouts(t *testing.T) {
+	d := schema.TestResourceDataRaw(t, resourceContainerHostExec().Schema, map[string]interface{}{
+		"command_timeout":          60,
+		"command_timeouts":         []interface{}{10},
+		"update_command_timeouts":  []interface{}{0, 3600},
+		"destroy_command_timeouts": []interface{}{},
+	})
+	assert.Equal(t, []time.Duration{10 * time.Second, time.Minute}, expandCommandTimeouts(d, "command_timeouts", 2))
+	assert.Equal(t, []time.Duration{time.Minute, time.Hour}, expandCommandTimeouts(d, "update_command_timeouts", 2))
+	assert.Equal(t, []time.Duration{time.Minute}, expandCommandTimeouts(d, "destroy_command_timeouts", 1))
+}
@@ -0,0 +1,289 @@
+package hsdp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+const (
+	// maxParallelUploads stays below the default MaxSessions of 10 of OpenSSH, as all
+	// uploads share a single connection
+	maxParallelUploads = 8
+	fileChecksumsField = "file_checksums"
+)
+
+// fileChecksum returns the SHA256 hex digest of the content that will be written to the destination
+func fileChecksum(f provisionFile) (string, error) {
+	hash := sha256.New()
+	if f.Source != "" {
+		src, err := os.Open(f.Source)
+		if err != nil {
+			return "", err
+		}
+		defer src.Close()
+		if _, err := io.Copy(hash, src); err != nil {
+			return "", err
+		}
+	} else {
+		_, _ = hash.Write([]byte(f.Content))
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fileChecksums returns the checksum of each file keyed by destination
+func fileChecksums(files []provisionFile) (map[string]string, error) {
+	checksums := make(map[string]string)
+	for _, f := range files {
+		checksum, err := fileChecksum(f)
+		if err != nil {
+			return nil, fmt.Errorf("checksum of %s: %w", f.Destination, err)
+		}
+		checksums[f.Destination] = checksum
+	}
+	return checksums, nil
+}
+
+// changedFiles returns the files whose checksum differs from what is known to be on the host
+func changedFiles(files []provisionFile, current, expected map[string]string) []provisionFile {
+	var changed []provisionFile
+	for _, f := range files {
+		if c, ok := current[f.Destination]; !ok || c != expected[f.Destination] {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
+
+// filePermissions matches the octal modes accepted for the permissions of a file
+var filePermissions = regexp.MustCompile(`^[0-7]{3,4}$`)
+
+// shellQuote quotes a value for use as a single POSIX shell word
+func shellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+const (
+	remoteFileMissing    = "missing"
+	remoteFileUnreadable = "unreadable"
+)
+
+// parseChecksums parses the output of sha256sum into a map keyed by path. Instead of a
+// checksum the status of missing or unreadable files is reported
+func parseChecksums(output string) map[string]string {
+	checksums := make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.SplitN(strings.TrimSpace(line), "  ", 2)
+		if len(fields) != 2 {
+			continue
+		}
+		checksums[fields[1]] = fields[0]
+	}
+	return checksums
+}
+
+// remoteChecksums computes the checksums of the destinations on the host. Files which do
+// not exist are returned with an empty checksum. Files which exist but can not be read
+// are not returned, but listed separately as their state is unknown
+func remoteChecksums(ssh *sshConfig, destinations []string) (map[string]string, []string, error) {
+	if len(destinations) == 0 {
+		return map[string]string{}, nil, nil
+	}
+	stdout, stderr, _, err := ssh.Run(remoteChecksumsScript(destinations))
+	if err != nil {
+		return nil, nil, fmt.Errorf("remote checksums: %w: %s", err, stderr)
+	}
+	checksums, unreadable := collectRemoteChecksums(stdout, destinations)
+	return checksums, unreadable, nil
+}
+
+// remoteChecksumsScript prints the checksum, or the missing or unreadable status, of each destination
+func remoteChecksumsScript(destinations []string) string {
+	quoted := make([]string, 0, len(destinations))
+	for _, dest := range destinations {
+		quoted = append(quoted, shellQuote(dest))
+	}
+	return fmt.Sprintf(`for f in %s; do if [ ! -e "$f" ]; then echo "%s  $f"; `+
+		`elif ! sha256sum -- "$f" 2>/dev/null; then echo "%s  $f"; fi; done`,
+		strings.Join(quoted, " "), remoteFileMissing, remoteFileUnreadable)
+}
+
+// collectRemoteChecksums splits the output of remoteChecksumsScript in checksums and unreadable files
+func collectRemoteChecksums(output string, destinations []string) (map[string]string, []string) {
+	found := parseChecksums(output)
+	checksums := make(map[string]string)
+	var unreadable []string
+	for _, dest := range destinations {
+		switch found[dest] {
+		case remoteFileUnreadable:
+			unreadable = append(unreadable, dest)
+		case remoteFileMissing:
+			checksums[dest] = ""
+		default:
+			checksums[dest] = found[dest]
+		}
+	}
+	sort.Strings(unreadable)
+	return checksums, unreadable
+}
+
+// changedFilesEnv exports the changed destinations so commands can act on them. The
+// destinations are separated by newlines, so paths with spaces are kept intact
+func changedFilesEnv(files []provisionFile) string {
+	destinations := make([]string, 0, len(files))
+	for _, f := range files {
+		destinations = append(destinations, f.Destination)
+	}
+	sort.Strings(destinations)
+	return fmt.Sprintf("export CHANGED_FILES=%s; ", shellQuote(strings.Join(destinations, "\n")))
+}
+
+// customizeContainerHostFilesDiff plans a change of the checksums when files
+// are changed in the configuration or drifted on the host
+func customizeContainerHostFilesDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.NewValueKnown(fileField) {
+		return d.SetNewComputed(fileChecksumsField)
+	}
+	var files []provisionFile
+	for _, vi := range d.Get(fileField).(*schema.Set).List() {
+		file, err := renderFile(expandProvisionFile(vi.(map[string]interface{})))
+		if os.IsNotExist(err) { // Source may not exist yet, e.g. when it is generated during apply
+			return d.SetNewComputed(fileChecksumsField)
+		}
+		if err != nil {
+			return fmt.Errorf("file %s: %w", file.Destination, err)
+		}
+		files = append(files, file)
+	}
+	expected, err := fileChecksums(files)
+	if err != nil {
+		return d.SetNewComputed(fileChecksumsField)
+	}
+	current := make(map[string]string)
+	for k, v := range d.Get(fileChecksumsField).(map[string]interface{}) {
+		current[k] = v.(string)
+	}
+	if len(current) == len(expected) && len(changedFiles(files, current, expected)) == 0 {
+		return nil
+	}
+	return d.SetNew(fileChecksumsField, expected)
+}
+
+// copyFile writes a single file to the host and sets its permissions and ownership
+// fileAttributesCommand returns a single command which changes the permissions and ownership of the file
+func fileAttributesCommand(f provisionFile) string {
+	var attributes []string
+	if f.Permissions != "" {
+		attributes = append(attributes, "chmod "+shellQuote(f.Permissions)+" "+shellQuote(f.Destination))
+	}
+	if f.Owner != "" {
+		attributes = append(attributes, "chown "+shellQuote(f.Owner)+" "+shellQuote(f.Destination))
+	}
+	if f.Group != "" {
+		attributes = append(attributes, "chgrp "+shellQuote(f.Group)+" "+shellQuote(f.Destination))
+	}
+	return strings.Join(attributes, " && ")
+}
+
+func copyFile(ssh *sshConfig, config *Config, f provisionFile) error {
+	if f.Source != "" {
+		src, srcErr := os.Open(f.Source)
+		if srcErr != nil {
+			_, _ = config.Debug("Failed to open source file %s: %v\n", f.Source, srcErr)
+			return srcErr
+		}
+		defer src.Close()
+		srcStat, statErr := src.Stat()
+		if statErr != nil {
+			_, _ = config.Debug("Failed to stat source file %s: %v\n", f.Source, statErr)
+			return fmt.Errorf("copyFiles: %w", statErr)
+		}
+		err := ssh.WriteFile(src, srcStat.Size(), f.Destination)
+		if err != nil {
+			_, _ = config.Debug("Error copying %s to remote file %s:%s: %v\n", f.Source, ssh.Server, f.Destination, err)
+			return fmt.Errorf("copyFiles: %w", err)
+		}
+		_, _ = config.Debug("Copied %s to remote file %s:%s: %d bytes\n", f.Source, ssh.Server, f.Destination, srcStat.Size())
+	} else {
+		buffer := bytes.NewBufferString(f.Content)
+		err := ssh.WriteFile(buffer, int64(buffer.Len()), f.Destination)
+		if err != nil {
+			_, _ = config.Debug("Error copying content to remote file %s:%s: %v\n", ssh.Server, f.Destination, err)
+			return fmt.Errorf("copyFiles: %w", err)
+		}
+		_, _ = config.Debug("Created remote file %s:%s: %d bytes\n", ssh.Server, f.Destination, len(f.Content))
+	}
+	if cmd := fileAttributesCommand(f); cmd != "" {
+		outStr, errStr, _, err := ssh.Run(cmd)
+		_, _ = config.Debug("Attributes file %s: %s/%s/%s: %v %v\n", f.Destination, f.Permissions, f.Owner, f.Group, outStr, errStr)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// copyFiles uploads the files to the host, at most maxParallelUploads at a time
+func copyFiles(ssh *sshConfig, config *Config, createFiles []provisionFile) error {
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []string
+	sem := make(chan struct{}, maxParallelUploads)
+
+	for _, f := range createFiles {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(f provisionFile) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if err := copyFile(ssh, config, f); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Sprintf("%s: %v", f.Destination, err))
+				mu.Unlock()
+			}
+		}(f)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("copyFiles: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// changedFileAttributes returns the files, not already in skip, whose permissions or ownership changed
+func changedFileAttributes(d *schema.ResourceData, files, skip []provisionFile) []provisionFile {
+	o, _ := d.GetChange(fileField)
+	previous := make(map[string]map[string]interface{})
+	for _, vi := range o.(*schema.Set).List() {
+		mVi := vi.(map[string]interface{})
+		previous[mVi["destination"].(string)] = mVi
+	}
+	skipped := make(map[string]bool)
+	for _, f := range skip {
+		skipped[f.Destination] = true
+	}
+	var changed []provisionFile
+	for _, f := range files {
+		if skipped[f.Destination] {
+			continue
+		}
+		p, ok := previous[f.Destination]
+		if !ok || p["permissions"] != f.Permissions || p["owner"] != f.Owner || p["group"] != f.Group {
+			changed = append(changed, f)
+		}
+	}
+	return changed
+}
@@ -0,0 +1,121 @@
+package hsdp
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileChecksum(t *testing.T) {
+	checksum, err := fileChecksum(provisionFile{Content: "hello"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", checksum)
+
+	tmp, err := ioutil.TempFile("", "checksum")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, _ = tmp.WriteString("hello")
+	_ = tmp.Close()
+
+	fromSource, err := fileChecksum(provisionFile{Source: tmp.Name()})
+	assert.Nil(t, err)
+	assert.Equal(t, checksum, fromSource)
+
+	_, err = fileChecksum(provisionFile{Source: tmp.Name() + ".missing"})
+	assert.NotNil(t, err)
+}
+
+func TestChangedFiles(t *testing.T) {
+	files := []provisionFile{
+		{Destination: "/a", Content: "a"},
+		{Destination: "/b", Content: "b"},
+		{Destination: "/c", Content: "c"},
+	}
+	expected, err := fileChecksums(files)
+	if !assert.Nil(t, err) {
+		return
+	}
+	current := map[string]string{
+		"/a": expected["/a"],
+		"/b": "", // Deleted on the host
+	}
+	changed := changedFiles(files, current, expected)
+	if assert.Len(t, changed, 2) {
+		assert.Equal(t, "/b", changed[0].Destination)
+		assert.Equal(t, "/c", changed[1].Destination)
+	}
+	assert.Equal(t, "export CHANGED_FILES='/b\n/c'; ", changedFilesEnv(changed))
+}
+
+func TestParseChecksums(t *testing.T) {
+	output := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824  /etc/app/config.yml\n" +
+		"ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb  /home/user/with space.txt\n" +
+		"garbage\n"
+	checksums := parseChecksums(output)
+	assert.Len(t, checksums, 2)
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", checksums["/etc/app/config.yml"])
+	assert.Equal(t, "ca978112ca1bbdcafac231b39a23dc4da786eff8147c4e72b9807785afee48bb", checksums["/home/user/with space.txt"])
+}
+
+func TestRemoteChecksumsScript(t *testing.T) {
+	if _, err := exec.LookPath("sha256sum"); err != nil {
+		t.Skip("sha256sum not available")
+	}
+	dir := t.TempDir()
+	present := filepath.Join(dir, "it's here.txt")
+	_ = ioutil.WriteFile(present, []byte("hello"), 0600)
+	missing := filepath.Join(dir, "missing.txt")
+	unreadable := filepath.Join(dir, "a directory")
+	_ = os.Mkdir(unreadable, 0700)
+
+	destinations := []string{present, missing, unreadable}
+	output, err := exec.Command("sh", "-c", remoteChecksumsScript(destinations)).Output()
+	if !assert.Nil(t, err) {
+		return
+	}
+	checksums, failed := collectRemoteChecksums(string(output), destinations)
+	assert.Equal(t, map[string]string{
+		present: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		missing: "",
+	}, checksums)
+	assert.Equal(t, []string{unreadable}, failed)
+
+	// The changed files survive the shell intact
+	output, err = exec.Command("sh", "-c", changedFilesEnv([]provisionFile{{Destination: present}, {Destination: "/b"}})+`printf '%s' "$CHANGED_FILES"`).Output()
+	if assert.Nil(t, err) {
+		assert.Equal(t, "/b\n"+present, string(output))
+	}
+}
+
+func TestFileAttributesCommand(t *testing.T) {
+	dir := t.TempDir()
+	destination := filepath.Join(dir, "it's $HOME `id`.txt")
+	_ = ioutil.WriteFile(destination, []byte("hello"), 0600)
+
+	assert.Equal(t, "", fileAttributesCommand(provisionFile{Destination: destination}))
+	cmd := fileAttributesCommand(provisionFile{Destination: destination, Permissions: "0640"})
+	if !assert.Nil(t, exec.Command("sh", "-c", cmd).Run()) {
+		return
+	}
+	info, err := os.Stat(destination)
+	if assert.Nil(t, err) {
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+	}
+	assert.Equal(t, `chmod '0640' '/a'\''b' && chown 'root;id' '/a'\''b' && chgrp '$(id)' '/a'\''b'`,
+		fileAttributesCommand(provisionFile{Destination: "/a'b", Permissions: "0640", Owner: "root;id", Group: "$(id)"}))
+
+	for _, permissions := range []string{"644", "0644", "1777"} {
+		assert.True(t, filePermissions.MatchString(permissions), permissions)
+	}
+	for _, permissions := range []string{"", "u+x", "0648", "644; id", "07777"} {
+		assert.False(t, filePermissions.MatchString(permissions), permissions)
+	}
+}
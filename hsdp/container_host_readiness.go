@@ -0,0 +1,225 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+const readinessCheckField = "readiness_check"
+
+// readinessCheck probes the host with either a command, a TCP connect or an HTTP request.
+// TCP and HTTP probes are made from the bastion
+type readinessCheck struct {
+	Command          string
+	ExpectedExitCode int
+	TCPPort          int
+	HTTPPort         int
+	HTTPPath         string
+	HTTPStatus       int
+	Retries          int
+	Interval         time.Duration
+	Timeout          time.Duration
+	CheckOnRefresh   bool
+}
+
+func readinessCheckSchema() *schema.Schema {
+	probes := []string{
+		readinessCheckField + ".0.command",
+		readinessCheckField + ".0.tcp_port",
+		readinessCheckField + ".0.http_port",
+	}
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 1,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"command": {
+					Type:         schema.TypeString,
+					Optional:     true,
+					ExactlyOneOf: probes,
+				},
+				"expected_exit_code": {
+					Type:     schema.TypeInt,
+					Optional: true,
+					Default:  0,
+				},
+				"tcp_port": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ExactlyOneOf: probes,
+					ValidateFunc: validation.IsPortNumber,
+				},
+				"http_port": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					ExactlyOneOf: probes,
+					ValidateFunc: validation.IsPortNumber,
+				},
+				"http_path": {
+					Type:     schema.TypeString,
+					Optional: true,
+					Default:  "/",
+				},
+				"http_status": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      http.StatusOK,
+					ValidateFunc: validation.IntBetween(100, 599),
+				},
+				"retries": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      30,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+				"interval": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      10,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"timeout": {
+					Type:         schema.TypeInt,
+					Optional:     true,
+					Default:      10,
+					ValidateFunc: validation.IntAtLeast(1),
+				},
+				"check_on_refresh": {
+					Type:     schema.TypeBool,
+					Optional: true,
+					Default:  false,
+				},
+			},
+		},
+	}
+}
+
+// expandReadinessCheck returns the configured readiness_check, ok is false when there is none
+func expandReadinessCheck(d *schema.ResourceData) (check readinessCheck, ok bool) {
+	checks := d.Get(readinessCheckField).([]interface{})
+	if len(checks) == 0 || checks[0] == nil {
+		return check, false
+	}
+	m := checks[0].(map[string]interface{})
+	return readinessCheck{
+		Command:          m["command"].(string),
+		ExpectedExitCode: m["expected_exit_code"].(int),
+		TCPPort:          m["tcp_port"].(int),
+		HTTPPort:         m["http_port"].(int),
+		HTTPPath:         m["http_path"].(string),
+		HTTPStatus:       m["http_status"].(int),
+		Retries:          m["retries"].(int),
+		Interval:         time.Duration(m["interval"].(int)) * time.Second,
+		Timeout:          time.Duration(m["timeout"].(int)) * time.Second,
+		CheckOnRefresh:   m["check_on_refresh"].(bool),
+	}, true
+}
+
+// needsSSH reports whether the check logs in on the host, TCP and HTTP probes only use the bastion
+func (r readinessCheck) needsSSH() bool {
+	return r.Command != ""
+}
+
+// probe runs the check once
+func (r readinessCheck) probe(ssh *sshConfig) error {
+	switch {
+	case r.Command != "":
+		_, err := ssh.Stream(r.Command, r.Timeout, io.Discard, io.Discard)
+		code := exitCode(err)
+		if code == commandExitCodeUnknown {
+			return err
+		}
+		if code != r.ExpectedExitCode {
+			return fmt.Errorf("command [%s] exited with %d, expected %d", r.Command, code, r.ExpectedExitCode)
+		}
+		return nil
+	case r.TCPPort > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+		defer cancel()
+		conn, err := ssh.DialTCP(ctx, r.TCPPort)
+		if err != nil {
+			return fmt.Errorf("port %d: %w", r.TCPPort, err)
+		}
+		return conn.Close()
+	default:
+		// The client timeout cancels the dial, keep-alives are off so no connection outlives the probe
+		client := &http.Client{
+			Timeout: r.Timeout,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return ssh.DialTCP(ctx, r.HTTPPort)
+				},
+				DisableKeepAlives: true,
+			},
+		}
+		resp, err := client.Get(fmt.Sprintf("http://%s%s", net.JoinHostPort(ssh.Server, fmt.Sprint(r.HTTPPort)), r.HTTPPath))
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode != r.HTTPStatus {
+			return fmt.Errorf("GET %s returned %d, expected %d", r.HTTPPath, resp.StatusCode, r.HTTPStatus)
+		}
+		return nil
+	}
+}
+
+// wait probes the host until the check passes or the retries are exhausted
+func (r readinessCheck) wait(ssh *sshConfig, config *Config) error {
+	attempt := 0
+	operation := func() error {
+		attempt++
+		err := r.probe(ssh)
+		_, _ = config.Debug("readiness check %s attempt %d: %v\n", ssh.Server, attempt, err)
+		return err
+	}
+	return backoff.Retry(operation, backoff.WithMaxRetries(backoff.NewConstantBackOff(r.Interval), uint64(r.Retries)))
+}
+
+// waitForHostReady runs the readiness_check. Without one, container-host instances fall back
+// to checking the Docker daemon
+func waitForHostReady(ssh *sshConfig, config *Config, d *schema.ResourceData) error {
+	check, ok := expandReadinessCheck(d)
+	if !ok {
+		if d.Get("instance_role").(string) == "container-host" {
+			return ensureContainerHostReady(ssh, config)
+		}
+		return nil
+	}
+	return check.wait(ssh, config)
+}
+
+// recheckHostReady probes the host once during refresh when check_on_refresh is enabled
+func recheckHostReady(ssh *sshConfig, d *schema.ResourceData) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	check, ok := expandReadinessCheck(d)
+	if !ok || !check.CheckOnRefresh {
+		return diags
+	}
+	if err := applyHostKeys(ssh, d); err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "readiness not checked",
+			Detail:   fmt.Sprintf("%v, the check is skipped until the next apply pins it", err),
+		})
+	}
+	if err := check.probe(ssh); err != nil {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "host is not ready",
+			Detail:   fmt.Sprintf("readiness check of '%s' failed: %v", containerHostName(d), err),
+		})
+	}
+	return diags
+}
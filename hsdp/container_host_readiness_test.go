@@ -0,0 +1,74 @@
+package hsdp
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessCheckProbe(t *testing.T) {
+	addr, fingerprint, stop := startTestSSHServer(t, nil)
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+	cfg := &sshConfig{User: "test", Server: host, Port: port, Fingerprint: fingerprint}
+
+	check := readinessCheck{Command: "exit 3", ExpectedExitCode: 3, Timeout: 5 * time.Second}
+	assert.Nil(t, check.probe(cfg))
+	check.ExpectedExitCode = 0
+	assert.NotNil(t, check.probe(cfg))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	serverHost, serverPort, _ := net.SplitHostPort(server.Listener.Addr().String())
+	httpPort, _ := strconv.Atoi(serverPort)
+	direct := &sshConfig{Server: serverHost}
+
+	check = readinessCheck{TCPPort: httpPort, Timeout: 5 * time.Second}
+	assert.Nil(t, check.probe(direct))
+
+	check = readinessCheck{HTTPPort: httpPort, HTTPPath: "/health", HTTPStatus: http.StatusOK, Timeout: 5 * time.Second}
+	assert.Nil(t, check.probe(direct))
+	check.HTTPPath = "/"
+	assert.NotNil(t, check.probe(direct))
+}
+
+func TestReadinessCheckProbeTimesOut(t *testing.T) {
+	// A proxy which accepts connections but never answers the CONNECT
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	proxyURL, _ := url.Parse("http://" + listener.Addr().String())
+	cfg := &sshConfig{Server: "10.0.0.1", Proxy: http.ProxyURL(proxyURL)}
+
+	check := readinessCheck{TCPPort: 8080, Timeout: 200 * time.Millisecond}
+	start := time.Now()
+	assert.NotNil(t, check.probe(cfg))
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+	// The probe closed its connection to the proxy
+	conn := <-accepted
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.Copy(io.Discard, conn)
+	assert.Nil(t, err)
+}
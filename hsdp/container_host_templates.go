@@ -0,0 +1,105 @@
+package hsdp
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"text/template"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+const (
+	templateFormatGo  = "go"
+	templateFormatHCL = "hcl"
+)
+
+// expandProvisionFile converts a file block to a provisionFile
+func expandProvisionFile(mVi map[string]interface{}) provisionFile {
+	file := provisionFile{
+		Source:      mVi["source"].(string),
+		Content:     mVi["content"].(string),
+		Destination: mVi["destination"].(string),
+	}
+	file.Permissions, _ = mVi["permissions"].(string)
+	file.Owner, _ = mVi["owner"].(string)
+	file.Group, _ = mVi["group"].(string)
+	file.TemplateFormat, _ = mVi["template_format"].(string)
+	if vars, ok := mVi["template_vars"].(map[string]interface{}); ok && len(vars) > 0 {
+		file.TemplateVars = make(map[string]string)
+		for k, v := range vars {
+			file.TemplateVars[k], _ = v.(string)
+		}
+	}
+	return file
+}
+
+// renderFile renders the file as a template when template_vars or template_format
+// are set. The rendered file always carries its output in Content
+func renderFile(f provisionFile) (provisionFile, error) {
+	if len(f.TemplateVars) == 0 && f.TemplateFormat == "" {
+		return f, nil
+	}
+	text := f.Content
+	if f.Source != "" {
+		data, err := ioutil.ReadFile(f.Source)
+		if err != nil {
+			return f, err
+		}
+		text = string(data)
+	}
+	var rendered string
+	var err error
+	switch f.TemplateFormat {
+	case "", templateFormatGo:
+		rendered, err = renderGoTemplate(f.Destination, text, f.TemplateVars)
+	case templateFormatHCL:
+		rendered, err = renderHCLTemplate(f.Destination, text, f.TemplateVars)
+	default:
+		err = fmt.Errorf("unsupported template format '%s'", f.TemplateFormat)
+	}
+	if err != nil {
+		return f, err
+	}
+	f.Source = ""
+	f.Content = rendered
+	return f, nil
+}
+
+// renderGoTemplate renders a text/template, variables are referenced as {{ .name }}
+func renderGoTemplate(name, text string, vars map[string]string) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("parsing template: %w", err)
+	}
+	if vars == nil {
+		vars = map[string]string{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return "", fmt.Errorf("rendering template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// renderHCLTemplate renders a template using the same syntax as templatefile(), variables are referenced as ${name}
+func renderHCLTemplate(name, text string, vars map[string]string) (string, error) {
+	expr, diags := hclsyntax.ParseTemplate([]byte(text), name, hcl.Pos{Line: 1, Column: 1})
+	if diags.HasErrors() {
+		return "", fmt.Errorf("parsing template: %s", diags.Error())
+	}
+	variables := make(map[string]cty.Value)
+	for k, v := range vars {
+		variables[k] = cty.StringVal(v)
+	}
+	value, diags := expr.Value(&hcl.EvalContext{Variables: variables})
+	if diags.HasErrors() {
+		return "", fmt.Errorf("rendering template: %s", diags.Error())
+	}
+	if value.IsNull() || !value.IsKnown() || value.Type() != cty.String {
+		return "", fmt.Errorf("rendering template: result is not a string")
+	}
+	return value.AsString(), nil
+}
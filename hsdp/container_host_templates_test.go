@@ -0,0 +1,63 @@
+package hsdp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderFile(t *testing.T) {
+	plain := provisionFile{Destination: "/plain", Content: "{{ .untouched }}"}
+	rendered, err := renderFile(plain)
+	assert.Nil(t, err)
+	assert.Equal(t, plain, rendered)
+
+	goFile := provisionFile{
+		Destination:  "/etc/app.conf",
+		Content:      "user={{ .user }}\npassword={{ .password }}\n",
+		TemplateVars: map[string]string{"user": "admin", "password": "s3cr3t"},
+	}
+	rendered, err = renderFile(goFile)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "user=admin\npassword=s3cr3t\n", rendered.Content)
+	}
+
+	hclFile := provisionFile{
+		Destination:    "/etc/app.conf",
+		Content:        "user=${user}\n%{ if user == \"admin\" }admin=true%{ endif }",
+		TemplateVars:   map[string]string{"user": "admin"},
+		TemplateFormat: templateFormatHCL,
+	}
+	rendered, err = renderFile(hclFile)
+	if assert.Nil(t, err) {
+		assert.Equal(t, "user=admin\nadmin=true", rendered.Content)
+	}
+
+	missing := provisionFile{
+		Destination:  "/etc/app.conf",
+		Content:      "{{ .unknown }}",
+		TemplateVars: map[string]string{"user": "admin"},
+	}
+	_, err = renderFile(missing)
+	assert.NotNil(t, err)
+
+	missing.TemplateFormat = templateFormatHCL
+	missing.Content = "${unknown}"
+	_, err = renderFile(missing)
+	assert.NotNil(t, err)
+}
+
+func TestRenderedChecksumFollowsVars(t *testing.T) {
+	file := provisionFile{
+		Destination:  "/etc/app.conf",
+		Content:      "password={{ .password }}",
+		TemplateVars: map[string]string{"password": "one"},
+	}
+	first, _ := renderFile(file)
+	file.TemplateVars = map[string]string{"password": "two"}
+	second, _ := renderFile(file)
+
+	a, _ := fileChecksum(first)
+	b, _ := fileChecksum(second)
+	assert.NotEqual(t, a, b)
+}
@@ -2,15 +2,44 @@ package hsdp
 
 import (
 	"context"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/cartel"
 )
 
+// instanceDetailsBatchSize limits the number of name tags per instance_details call
+const instanceDetailsBatchSize = 50
+
 func dataSourceContainerHostInstances() *schema.Resource {
 	return &schema.Resource{
 		ReadContext: dataSourceContainerHostInstancesRead,
 		Schema: map[string]*schema.Schema{
+			"tags": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"role": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"subnet_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ValidateFunc: validation.StringInSlice([]string{"private", "public"}, false),
+			},
+			"instance_type": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"state": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"names": {
 				Type:     schema.TypeList,
 				Computed: true,
@@ -36,11 +65,215 @@ func dataSourceContainerHostInstances() *schema.Resource {
 				Computed: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
+			"private_ips": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"instances": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"instance_type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"role": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"state": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"owner": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"private_ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"public_ip": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"launch_time": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"zone": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"vpc": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"subnet": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"subnet_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"protection": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"security_groups": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"user_groups": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+						"tags": {
+							Type:     schema.TypeMap,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
 		},
 	}
 
 }
 
+// instanceFilter holds the optional filters of the data source. Empty fields match everything
+type instanceFilter struct {
+	Tags         map[string]string
+	Role         string
+	SubnetType   string
+	InstanceType string
+	State        string
+}
+
+// containerHostSubnetType returns public for instances with a public address and private
+// otherwise, the same way hsdp_container_host reports its subnet_type
+func containerHostSubnetType(instance cartel.InstanceDetails) string {
+	if instance.PublicAddress != "" {
+		return "public"
+	}
+	return "private"
+}
+
+// mayMatch filters the instance list before the details are fetched. The list does not carry
+// all fields, so only fields which are present can exclude an instance
+func (f instanceFilter) mayMatch(instance cartel.InstanceDetails) bool {
+	if f.Role != "" && instance.Role != "" && instance.Role != f.Role {
+		return false
+	}
+	if f.InstanceType != "" && instance.InstanceType != "" && instance.InstanceType != f.InstanceType {
+		return false
+	}
+	if f.State != "" && instance.State != "" && !strings.EqualFold(instance.State, f.State) {
+		return false
+	}
+	if instance.Tags != nil {
+		for k, v := range f.Tags {
+			if tag, ok := instance.Tags[k]; !ok || (v != "" && tag != v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matches reports whether the instance, with its details, passes the filter
+func (f instanceFilter) matches(instance cartel.InstanceDetails) bool {
+	if f.Role != "" && instance.Role != f.Role {
+		return false
+	}
+	if f.InstanceType != "" && instance.InstanceType != f.InstanceType {
+		return false
+	}
+	if f.State != "" && !strings.EqualFold(instance.State, f.State) {
+		return false
+	}
+	if f.SubnetType != "" && containerHostSubnetType(instance) != f.SubnetType {
+		return false
+	}
+	for k, v := range f.Tags {
+		if tag, ok := instance.Tags[k]; !ok || (v != "" && tag != v) {
+			return false
+		}
+	}
+	return true
+}
+
+// candidateInstances returns the instances of the list which may match the filter
+func candidateInstances(instances []cartel.InstanceDetails, filter instanceFilter) []cartel.InstanceDetails {
+	var candidates []cartel.InstanceDetails
+	for _, instance := range instances {
+		if filter.mayMatch(instance) {
+			candidates = append(candidates, instance)
+		}
+	}
+	return candidates
+}
+
+// filterInstances returns the matching instances sorted by name
+func filterInstances(instances []cartel.InstanceDetails, filter instanceFilter) []cartel.InstanceDetails {
+	var matched []cartel.InstanceDetails
+	for _, instance := range instances {
+		if filter.matches(instance) {
+			matched = append(matched, instance)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return matched[i].NameTag < matched[j].NameTag
+	})
+	return matched
+}
+
+// instanceDetails completes the instance list with the details of each instance, as the
+// list does not carry all fields
+func instanceDetails(client *cartel.Client, instances []cartel.InstanceDetails) ([]cartel.InstanceDetails, error) {
+	completed := make([]cartel.InstanceDetails, 0, len(instances))
+	for start := 0; start < len(instances); start += instanceDetailsBatchSize {
+		end := start + instanceDetailsBatchSize
+		if end > len(instances) {
+			end = len(instances)
+		}
+		var names []string
+		for _, instance := range instances[start:end] {
+			names = append(names, instance.NameTag)
+		}
+		details, _, err := client.GetDetailsMulti(names...)
+		if err != nil {
+			return nil, err
+		}
+		for _, instance := range instances[start:end] {
+			if detail, ok := (*details)[instance.NameTag]; ok {
+				if detail.NameTag == "" {
+					detail.NameTag = instance.NameTag
+				}
+				if detail.Owner == "" {
+					detail.Owner = instance.Owner
+				}
+				instance = detail
+			}
+			completed = append(completed, instance)
+		}
+	}
+	return completed, nil
+}
+
 func dataSourceContainerHostInstancesRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -55,6 +288,31 @@ func dataSourceContainerHostInstancesRead(_ context.Context, d *schema.ResourceD
 		return diag.FromErr(err)
 	}
 
+	filter := instanceFilter{
+		Tags:         make(map[string]string),
+		Role:         d.Get("role").(string),
+		SubnetType:   d.Get("subnet_type").(string),
+		InstanceType: d.Get("instance_type").(string),
+		State:        d.Get("state").(string),
+	}
+	for k, v := range d.Get("tags").(map[string]interface{}) {
+		filter.Tags[k], _ = v.(string)
+	}
+	// Details are only fetched for the instances which may match
+	candidates, err := instanceDetails(client, candidateInstances(*instances, filter))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	matched := filterInstances(candidates, filter)
+	subnetNames := make(map[string]string)
+	if len(matched) > 0 {
+		if subnets, _, err := client.GetAllSubnets(); err == nil {
+			for name, subnet := range *subnets {
+				subnetNames[subnet.ID] = name
+			}
+		}
+	}
+
 	d.SetId("cartel_instances")
 
 	var names []string
@@ -62,19 +320,41 @@ func dataSourceContainerHostInstancesRead(_ context.Context, d *schema.ResourceD
 	var types []string
 	var privateIPs []string
 	var owners []string
+	var details []map[string]interface{}
 
-	for _, instance := range *instances {
+	for _, instance := range matched {
 		names = append(names, instance.NameTag)
 		ids = append(ids, instance.InstanceID)
 		types = append(types, instance.InstanceType)
 		privateIPs = append(privateIPs, instance.PrivateAddress)
 		owners = append(owners, instance.Owner)
+		details = append(details, map[string]interface{}{
+			"id":              instance.InstanceID,
+			"name":            instance.NameTag,
+			"instance_type":   instance.InstanceType,
+			"role":            instance.Role,
+			"state":           instance.State,
+			"owner":           instance.Owner,
+			"private_ip":      instance.PrivateAddress,
+			"public_ip":       instance.PublicAddress,
+			"launch_time":     instance.LaunchTime,
+			"zone":            instance.Zone,
+			"vpc":             instance.Vpc,
+			"subnet":          instance.Subnet,
+			"subnet_name":     subnetNames[instance.Subnet],
+			"protection":      instance.Protection,
+			"security_groups": instance.SecurityGroups,
+			"user_groups":     []string(instance.LdapGroups),
+			"tags":            instance.Tags,
+		})
 	}
 	_ = d.Set("names", names)
 	_ = d.Set("ids", ids)
 	_ = d.Set("types", types)
 	_ = d.Set("owners", owners)
+	_ = d.Set("private_addresses", privateIPs)
 	_ = d.Set("private_ips", privateIPs)
+	_ = d.Set("instances", details)
 
 	return diags
 }
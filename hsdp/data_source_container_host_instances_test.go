@@ -0,0 +1,108 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/cartel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterInstances(t *testing.T) {
+	instances := []cartel.InstanceDetails{
+		{NameTag: "logging-2", Role: "container-host", State: "running", InstanceType: "m5.large",
+			Tags: map[string]string{"service": "logging", "env": "prod"}},
+		{NameTag: "logging-1", Role: "container-host", State: "stopped", PublicAddress: "1.2.3.4", InstanceType: "m5.large",
+			Tags: map[string]string{"service": "logging"}},
+		{NameTag: "vault", Role: "vault", State: "running", InstanceType: "t3.small"},
+	}
+
+	all := filterInstances(instances, instanceFilter{})
+	if assert.Len(t, all, 3) {
+		assert.Equal(t, "logging-1", all[0].NameTag)
+	}
+
+	byTag := filterInstances(instances, instanceFilter{Tags: map[string]string{"service": "logging"}})
+	assert.Len(t, byTag, 2)
+
+	byTagKey := filterInstances(instances, instanceFilter{Tags: map[string]string{"env": ""}})
+	if assert.Len(t, byTagKey, 1) {
+		assert.Equal(t, "logging-2", byTagKey[0].NameTag)
+	}
+
+	combined := filterInstances(instances, instanceFilter{
+		Role:         "container-host",
+		SubnetType:   "private",
+		InstanceType: "m5.large",
+		State:        "Running",
+	})
+	if assert.Len(t, combined, 1) {
+		assert.Equal(t, "logging-2", combined[0].NameTag)
+	}
+	public := filterInstances(instances, instanceFilter{SubnetType: "public"})
+	if assert.Len(t, public, 1) {
+		assert.Equal(t, "logging-1", public[0].NameTag)
+	}
+
+	assert.Len(t, filterInstances(instances, instanceFilter{Role: "gateway"}), 0)
+}
+
+func TestCandidateInstances(t *testing.T) {
+	// The list may lack fields, those can not exclude an instance
+	instances := []cartel.InstanceDetails{
+		{NameTag: "a", InstanceType: "m5.large"},
+		{NameTag: "b", InstanceType: "t3.small"},
+		{NameTag: "c", Role: "vault"},
+		{NameTag: "d", Tags: map[string]string{"service": "other"}},
+	}
+	filter := instanceFilter{Role: "container-host", InstanceType: "m5.large", Tags: map[string]string{"service": "logging"}}
+	candidates := candidateInstances(instances, filter)
+	if assert.Len(t, candidates, 1) {
+		assert.Equal(t, "a", candidates[0].NameTag)
+	}
+}
+
+func TestDataSourceContainerHostInstancesFetchesOnlyCandidates(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "get_all_instances"):
+			_, _ = w.Write([]byte(`[
+				{"instance_id":"i-1","name_tag":"logging-1","instance_type":"m5.large"},
+				{"instance_id":"i-2","name_tag":"vault","instance_type":"t3.small"}]`))
+		case strings.HasSuffix(r.URL.Path, "instance_details"):
+			var body struct {
+				NameTag []string `json:"name-tag"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			requested = append(requested, body.NameTag...)
+			_, _ = w.Write([]byte(`[{"logging-1":{"instance_id":"i-1","instance_type":"m5.large","role":"container-host","state":"running","private_address":"10.0.0.1"}}]`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	config.setupCartelClient()
+
+	ds := dataSourceContainerHostInstances()
+	d := ds.TestResourceData()
+	_ = d.Set("instance_type", "m5.large")
+	_ = d.Set("subnet_type", "private")
+	diags := ds.ReadContext(context.Background(), d, config)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, []string{"logging-1"}, requested)
+	assert.Equal(t, []interface{}{"10.0.0.1"}, d.Get("private_ips"))
+}
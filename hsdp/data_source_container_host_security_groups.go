@@ -0,0 +1,120 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/philips-software/go-hsdp-api/cartel"
+)
+
+func dataSourceContainerHostSecurityGroups() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceContainerHostSecurityGroupsRead,
+		Schema: map[string]*schema.Schema{
+			"filter_names": {
+				Type:     schema.TypeSet,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"names": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"security_groups": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"rule": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem: &schema.Resource{
+								Schema: map[string]*schema.Schema{
+									"port_range": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"protocol": {
+										Type:     schema.TypeString,
+										Computed: true,
+									},
+									"sources": {
+										Type:     schema.TypeList,
+										Computed: true,
+										Elem:     &schema.Schema{Type: schema.TypeString},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// flattenSecurityRules converts the rules of a security group to rule blocks
+func flattenSecurityRules(rules cartel.SecurityGroupDetails) []map[string]interface{} {
+	flattened := make([]map[string]interface{}, 0, len(rules))
+	for _, r := range rules {
+		flattened = append(flattened, map[string]interface{}{
+			"port_range": r.PortRange,
+			"protocol":   r.Protocol,
+			"sources":    r.Source,
+		})
+	}
+	return flattened
+}
+
+func dataSourceContainerHostSecurityGroupsRead(_ context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+	var diags diag.Diagnostics
+
+	client, err := config.CartelClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	groups, _, err := client.GetSecurityGroups()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	names := *groups
+	if filter := expandStringList(d.Get("filter_names").(*schema.Set).List()); len(filter) > 0 {
+		var missing []string
+		for _, name := range filter {
+			if !containsString(names, name) {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return diag.FromErr(fmt.Errorf("unknown security group(s): %s", strings.Join(missing, ", ")))
+		}
+		names = filter
+	}
+	sort.Strings(names)
+
+	securityGroups := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		rules, _, err := client.GetSecurityGroupDetails(name)
+		if err != nil {
+			return diag.FromErr(fmt.Errorf("security group %s: %w", name, err))
+		}
+		securityGroups = append(securityGroups, map[string]interface{}{
+			"name": name,
+			"rule": flattenSecurityRules(*rules),
+		})
+	}
+	_ = d.Set("names", names)
+	_ = d.Set("security_groups", securityGroups)
+	d.SetId("security_groups")
+	return diags
+}
@@ -0,0 +1,131 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+// securityGroupsStub serves the Cartel security group endpoints. The first calls to
+// get_security_groups fail, as many as failures
+type securityGroupsStub struct {
+	failures int
+	calls    int
+}
+
+func (s *securityGroupsStub) start(t *testing.T) *Config {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasSuffix(r.URL.Path, "get_security_groups"):
+			s.calls++
+			if s.calls <= s.failures {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = w.Write([]byte(`{"description":"unavailable"}`))
+				return
+			}
+			_, _ = w.Write([]byte(`["http-from-cf","https-from-cf","tcp-5432"]`))
+		case strings.HasSuffix(r.URL.Path, "security_group_details"):
+			var body struct {
+				SecurityGroup []string `json:"security_group"`
+			}
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			details := map[string]interface{}{}
+			for _, g := range body.SecurityGroup {
+				details[g] = []map[string]interface{}{
+					{"port_range": "443", "protocol": "tcp", "source": []string{"10.0.0.0/8"}},
+				}
+			}
+			_ = json.NewEncoder(w).Encode(details)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(server.Close)
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	config.setupCartelClient()
+	return config
+}
+
+func TestDataSourceContainerHostSecurityGroups(t *testing.T) {
+	stub := &securityGroupsStub{}
+	config := stub.start(t)
+	ds := dataSourceContainerHostSecurityGroups()
+
+	d := ds.TestResourceData()
+	diags := ds.ReadContext(context.Background(), d, config)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, []interface{}{"http-from-cf", "https-from-cf", "tcp-5432"}, d.Get("names"))
+	assert.Equal(t, 3, d.Get("security_groups.#"))
+	assert.Equal(t, "443", d.Get("security_groups.0.rule.0.port_range"))
+	assert.Equal(t, "10.0.0.0/8", d.Get("security_groups.0.rule.0.sources.0"))
+
+	d = ds.TestResourceData()
+	_ = d.Set("filter_names", []interface{}{"tcp-5432"})
+	diags = ds.ReadContext(context.Background(), d, config)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, []interface{}{"tcp-5432"}, d.Get("names"))
+
+	d = ds.TestResourceData()
+	_ = d.Set("filter_names", []interface{}{"tcp-5433"})
+	diags = ds.ReadContext(context.Background(), d, config)
+	if assert.True(t, diags.HasError()) {
+		assert.Contains(t, diags[0].Summary, "tcp-5433")
+	}
+}
+
+func TestCartelSecurityGroupsRetriesFailures(t *testing.T) {
+	stub := &securityGroupsStub{failures: 1}
+	config := stub.start(t)
+
+	_, err := config.CartelSecurityGroups()
+	assert.NotNil(t, err)
+	groups, err := config.CartelSecurityGroups()
+	if assert.Nil(t, err) {
+		assert.Len(t, groups, 3)
+	}
+	_, _ = config.CartelSecurityGroups()
+	assert.Equal(t, 2, stub.calls)
+}
+
+func TestValidateSecurityGroupsDiff(t *testing.T) {
+	stub := &securityGroupsStub{}
+	config := stub.start(t)
+	r := resourceContainerHost()
+
+	plan := func(groups ...interface{}) error {
+		_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":            "host",
+			"security_groups": groups,
+		}), config)
+		return err
+	}
+	assert.Nil(t, plan("https-from-cf", "tcp-5432"))
+	if err := plan("https-from-fc"); assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "did you mean https-from-cf")
+	}
+	if err := plan("base"); assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "internal")
+	}
+
+	// Plans are not blocked when Cartel is unavailable
+	unavailable := (&securityGroupsStub{failures: 100}).start(t)
+	_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":            "host",
+		"security_groups": []interface{}{"anything"},
+	}), unavailable)
+	assert.Nil(t, err)
+}
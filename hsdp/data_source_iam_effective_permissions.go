@@ -0,0 +1,266 @@
+package hsdp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+// permissionGrant records through which group and role a permission is granted
+type permissionGrant struct {
+	Permission string
+	GroupID    string
+	GroupName  string
+	RoleID     string
+	RoleName   string
+}
+
+func dataSourceIAMEffectivePermissions() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIAMEffectivePermissionsRead,
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"user_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"user_id", "service_id"},
+			},
+			"service_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"permissions": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"groups": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"grants": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"permission": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"group_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"group_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"role_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"role_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// userGroupsInOrganization returns the groups a user is a member of in the given organization
+func userGroupsInOrganization(client *iam.Client, userID, orgID string) ([]iam.Group, error) {
+	user, _, err := client.Users.GetUserByID(userID)
+	if err != nil {
+		return nil, fmt.Errorf("get user %s: %w", userID, err)
+	}
+	var groups []iam.Group
+	for _, membership := range user.Memberships {
+		if membership.OrganizationID != orgID {
+			continue
+		}
+		for _, name := range membership.Groups {
+			groupName := name
+			group, _, err := client.Groups.GetGroup(&iam.GetGroupOptions{
+				Name:           &groupName,
+				OrganizationID: &orgID,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("get group %s: %w", name, err)
+			}
+			groups = append(groups, *group)
+		}
+	}
+	return groups, nil
+}
+
+// serviceGroupsInOrganization returns the groups a service identity is a member of in the given
+// organization. IAM has no group search by member, so the groups with service members are found
+// with the group search (orgID and memberType) and their members are read from the SCIM Groups API
+func serviceGroupsInOrganization(client *iam.Client, serviceID, orgID string) ([]iam.Group, error) {
+	memberType := "SERVICE"
+	candidates, _, err := client.Groups.GetGroups(&iam.GetGroupOptions{
+		OrganizationID: &orgID,
+		MemberType:     &memberType,
+	})
+	if errors.Is(err, iam.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("search groups with services in %s: %w", orgID, err)
+	}
+	var groups []iam.Group
+	for _, group := range *candidates {
+		members, err := scimGroupMembers(client, group.ID, memberType)
+		if err != nil {
+			return nil, err
+		}
+		if containsString(members, serviceID) {
+			groups = append(groups, group)
+		}
+	}
+	return groups, nil
+}
+
+// scimGroupMembers returns the IDs of the members of the given type, using the
+// includeGroupMembersType parameter of the SCIM Groups API
+func scimGroupMembers(client *iam.Client, groupID, memberType string) ([]string, error) {
+	query := url.Values{}
+	query.Set("includeGroupMembersType", memberType)
+
+	var group struct {
+		Extension struct {
+			GroupMembers struct {
+				TotalResults int `json:"totalResults"`
+				Resources    []struct {
+					ID string `json:"id"`
+				} `json:"Resources"`
+			} `json:"groupMembers"`
+		} `json:"urn:ietf:params:scim:schemas:extension:philips:hsdp:2.0:Group"`
+	}
+	if _, err := iamRequest(client, http.MethodGet, "authorize/scim/v2/Groups/"+groupID+"?"+query.Encode(), "1", nil, &group); err != nil {
+		return nil, fmt.Errorf("get members of group %s: %w", groupID, err)
+	}
+	members := group.Extension.GroupMembers
+	// Never report a partial membership, that would hide grants
+	if members.TotalResults > len(members.Resources) {
+		return nil, fmt.Errorf("group %s has %d %s members but only %d were returned", groupID,
+			members.TotalResults, memberType, len(members.Resources))
+	}
+	ids := make([]string, 0, len(members.Resources))
+	for _, m := range members.Resources {
+		ids = append(ids, m.ID)
+	}
+	return ids, nil
+}
+
+// resolvePermissionGrants expands the roles of the groups to their permissions
+func resolvePermissionGrants(client *iam.Client, groups []iam.Group) ([]permissionGrant, error) {
+	var grants []permissionGrant
+	rolePermissions := map[string][]string{}
+
+	for _, group := range groups {
+		roles, _, err := client.Roles.GetRolesByGroupID(group.ID)
+		if err != nil {
+			return nil, fmt.Errorf("get roles of group %s: %w", group.Name, err)
+		}
+		if roles == nil {
+			continue
+		}
+		for _, role := range *roles {
+			permissions, ok := rolePermissions[role.ID]
+			if !ok {
+				found, _, err := client.Roles.GetRolePermissions(role)
+				if err != nil {
+					return nil, fmt.Errorf("get permissions of role %s: %w", role.Name, err)
+				}
+				if found != nil {
+					permissions = *found
+				}
+				rolePermissions[role.ID] = permissions
+			}
+			for _, p := range permissions {
+				grants = append(grants, permissionGrant{
+					Permission: p,
+					GroupID:    group.ID,
+					GroupName:  group.Name,
+					RoleID:     role.ID,
+					RoleName:   role.Name,
+				})
+			}
+		}
+	}
+	sort.SliceStable(grants, func(i, j int) bool {
+		return grants[i].Permission < grants[j].Permission
+	})
+	return grants, nil
+}
+
+func dataSourceIAMEffectivePermissionsRead(_ context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	orgID := d.Get("organization_id").(string)
+	userID := d.Get("user_id").(string)
+	serviceID := d.Get("service_id").(string)
+
+	var groups []iam.Group
+	subject := userID
+	if userID != "" {
+		groups, err = userGroupsInOrganization(client, userID, orgID)
+	} else {
+		subject = serviceID
+		groups, err = serviceGroupsInOrganization(client, serviceID, orgID)
+	}
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	grants, err := resolvePermissionGrants(client, groups)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var permissions []string
+	var grantList []map[string]interface{}
+	for _, g := range grants {
+		if len(permissions) == 0 || permissions[len(permissions)-1] != g.Permission {
+			permissions = append(permissions, g.Permission)
+		}
+		grantList = append(grantList, map[string]interface{}{
+			"permission": g.Permission,
+			"group_id":   g.GroupID,
+			"group_name": g.GroupName,
+			"role_id":    g.RoleID,
+			"role_name":  g.RoleName,
+		})
+	}
+	var groupNames []string
+	for _, g := range groups {
+		groupNames = append(groupNames, g.Name)
+	}
+
+	d.SetId(subject + "-" + orgID)
+	_ = d.Set("permissions", permissions)
+	_ = d.Set("groups", groupNames)
+	_ = d.Set("grants", grantList)
+
+	return diags
+}
@@ -0,0 +1,119 @@
+package hsdp
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+// effectivePermissionsStub serves the IAM endpoints used to resolve effective permissions
+func effectivePermissionsStub(serviceMembers string) http.Handler {
+	groups := map[string]string{
+		"g-admin": `{"id":"g-admin","name":"ADMINS","managingOrganization":"org-1"}`,
+		"g-ops":   `{"id":"g-ops","name":"OPS","managingOrganization":"org-1"}`,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		q := r.URL.Query()
+		var body string
+		switch r.URL.Path {
+		case "/authorize/identity/User":
+			body = `{"total":1,"entry":[{"id":"user-1","memberships":[
+				{"organizationId":"org-1","groups":["ADMINS","OPS"]},
+				{"organizationId":"org-2","groups":["OTHER"]}]}]}`
+		case "/authorize/identity/Group":
+			switch {
+			case q.Get("name") == "ADMINS":
+				body = `{"total":1,"entry":[{"resource":{"_id":"g-admin"}}]}`
+			case q.Get("name") == "OPS":
+				body = `{"total":1,"entry":[{"resource":{"_id":"g-ops"}}]}`
+			case q.Get("memberType") == "SERVICE" && q.Get("orgID") == "org-1":
+				body = `{"total":2,"entry":[{"resource":{"_id":"g-admin"}},{"resource":{"_id":"g-ops"}}]}`
+			default:
+				body = `{"total":0,"entry":[]}`
+			}
+		case "/authorize/identity/Group/g-admin", "/authorize/identity/Group/g-ops":
+			body = groups[r.URL.Path[len("/authorize/identity/Group/"):]]
+		case "/authorize/scim/v2/Groups/g-admin":
+			body = `{"urn:ietf:params:scim:schemas:extension:philips:hsdp:2.0:Group":{"groupMembers":{"totalResults":1,"Resources":[{"id":"other-service"}]}}}`
+		case "/authorize/scim/v2/Groups/g-ops":
+			body = serviceMembers
+		case "/authorize/identity/Role":
+			switch q.Get("groupId") {
+			case "g-admin":
+				body = `{"total":1,"entry":[{"id":"r-admin","name":"ADMIN"}]}`
+			case "g-ops":
+				body = `{"total":1,"entry":[{"id":"r-ops","name":"OPERATOR"}]}`
+			}
+		case "/authorize/identity/Permission":
+			switch q.Get("roleId") {
+			case "r-admin":
+				body = `{"total":2,"entry":[{"name":"USER.WRITE"},{"name":"USER.READ"}]}`
+			case "r-ops":
+				body = `{"total":1,"entry":[{"name":"USER.READ"}]}`
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"responseCode":"404","responseMessage":"not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	})
+}
+
+func TestDataSourceIAMEffectivePermissions(t *testing.T) {
+	opsMembers := `{"urn:ietf:params:scim:schemas:extension:philips:hsdp:2.0:Group":{"groupMembers":{"totalResults":2,"Resources":[{"id":"other-service"},{"id":"service-1"}]}}}`
+	meta := newTestIAMConfig(t, effectivePermissionsStub(opsMembers))
+	r := dataSourceIAMEffectivePermissions()
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"organization_id": "org-1",
+		"user_id":         "user-1",
+	})
+	diags := r.ReadContext(context.Background(), d, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, "user-1-org-1", d.Id())
+	assert.Equal(t, []interface{}{"USER.READ", "USER.WRITE"}, d.Get("permissions"))
+	assert.Equal(t, []interface{}{"ADMINS", "OPS"}, d.Get("groups"))
+	assert.Equal(t, 3, d.Get("grants.#"))
+	assert.Equal(t, "USER.READ", d.Get("grants.0.permission"))
+	assert.Equal(t, "ADMINS", d.Get("grants.0.group_name"))
+	assert.Equal(t, "OPERATOR", d.Get("grants.1.role_name"))
+
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"organization_id": "org-1",
+		"service_id":      "service-1",
+	})
+	diags = r.ReadContext(context.Background(), d, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, []interface{}{"USER.READ"}, d.Get("permissions"))
+	assert.Equal(t, []interface{}{"OPS"}, d.Get("groups"))
+	assert.Equal(t, "r-ops", d.Get("grants.0.role_id"))
+
+	// No groups with service members in the organization
+	d = schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"organization_id": "org-2",
+		"service_id":      "service-1",
+	})
+	diags = r.ReadContext(context.Background(), d, meta)
+	if assert.False(t, diags.HasError(), "%v", diags) {
+		assert.Empty(t, d.Get("permissions"))
+	}
+}
+
+func TestServiceGroupsInOrganizationTruncated(t *testing.T) {
+	truncated := `{"urn:ietf:params:scim:schemas:extension:philips:hsdp:2.0:Group":{"groupMembers":{"totalResults":150,"Resources":[{"id":"other-service"}]}}}`
+	meta := newTestIAMConfig(t, effectivePermissionsStub(truncated))
+
+	_, err := serviceGroupsInOrganization(meta.iamClient, "service-1", "org-1")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "group g-ops has 150 SERVICE members but only 1 were returned")
+	}
+}
@@ -0,0 +1,123 @@
+package hsdp
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+// emailTemplateSampleValues are substituted for placeholders when rendering a preview
+var emailTemplateSampleValues = map[string]string{
+	"user.email":                  "jane.doe@example.com",
+	"user.userName":               "janedoe",
+	"user.givenName":              "Jane",
+	"user.familyName":             "Doe",
+	"user.lockoutPeriod":          "30",
+	"link.verification":           "https://example.com/verify?otp=123456",
+	"link.passwordReset":          "https://example.com/reset?otp=123456",
+	"link.passwordChange":         "https://example.com/change",
+	"template.linkExpiryPeriod":   "24",
+	"password.expiresAfterPeriod": "7",
+}
+
+func dataSourceIAMEmailTemplatePreview() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIAMEmailTemplatePreviewRead,
+		Schema: map[string]*schema.Schema{
+			"type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice(emailTemplateTypes(), false),
+			},
+			"format": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "HTML",
+			},
+			"subject": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  "default",
+			},
+			"message": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"link": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"sample_values": {
+				Type:     schema.TypeMap,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"rendered_subject": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"rendered_message": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"placeholders": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// renderEmailTemplate replaces all placeholders in text with the given values
+func renderEmailTemplate(text string, values map[string]string) string {
+	return placeholderRegexp.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderRegexp.FindStringSubmatch(match)[1]
+		if v, ok := values[name]; ok {
+			return v
+		}
+		return match
+	})
+}
+
+func dataSourceIAMEmailTemplatePreviewRead(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	templateType := d.Get("type").(string)
+	format := d.Get("format").(string)
+	subject := d.Get("subject").(string)
+	message := d.Get("message").(string)
+	link := d.Get("link").(string)
+
+	if err := validateEmailTemplate(templateType, format, subject, message); err != nil {
+		return diag.FromErr(err)
+	}
+
+	values := make(map[string]string)
+	for k, v := range emailTemplateSampleValues {
+		values[k] = v
+	}
+	if link != "" { // IAM appends an OTP to custom verification and reset links
+		values["link.verification"] = link + "?otp=123456"
+		values["link.passwordReset"] = link + "?otp=123456"
+		values["link.passwordChange"] = link
+	}
+	for k, v := range d.Get("sample_values").(map[string]interface{}) {
+		values[k] = v.(string)
+	}
+
+	renderedSubject := renderEmailTemplate(subject, values)
+	renderedMessage := renderEmailTemplate(message, values)
+
+	d.SetId(fmt.Sprintf("%x", sha256.Sum256([]byte(templateType+renderedSubject+renderedMessage))))
+	_ = d.Set("rendered_subject", renderedSubject)
+	_ = d.Set("rendered_message", renderedMessage)
+	_ = d.Set("placeholders", findPlaceholders(strings.Join([]string{subject, message}, "\n")))
+
+	return diags
+}
@@ -0,0 +1,245 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+const (
+	organizationAPIVersion = "2"
+	organizationsPageSize  = 100
+)
+
+func dataSourceIAMOrgTree() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIAMOrgTreeRead,
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"max_depth": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntAtLeast(0),
+			},
+			"include_counts": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"include_user_counts": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"organization_ids": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"organizations": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"display_name": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"type": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"external_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"active": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"parent_org_id": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"depth": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"group_count": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"service_count": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"user_count": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// getChildOrganizations returns the direct children of the given organization
+func getChildOrganizations(client *iam.Client, parentID string) ([]iam.Organization, error) {
+	var children []iam.Organization
+	startIndex := 1
+	for {
+		query := url.Values{}
+		query.Set("filter", fmt.Sprintf("parent.value eq \"%s\"", parentID))
+		query.Set("startIndex", strconv.Itoa(startIndex))
+		query.Set("count", strconv.Itoa(organizationsPageSize))
+
+		var page struct {
+			TotalResults int                `json:"totalResults"`
+			Resources    []iam.Organization `json:"Resources"`
+		}
+		_, err := iamRequest(client, http.MethodGet, "authorize/scim/v2/Organizations?"+query.Encode(), organizationAPIVersion, nil, &page)
+		if err != nil {
+			return nil, fmt.Errorf("listing children of %s: %w", parentID, err)
+		}
+		children = append(children, page.Resources...)
+		if len(page.Resources) == 0 || len(children) >= page.TotalResults {
+			return children, nil
+		}
+		startIndex += len(page.Resources)
+	}
+}
+
+// getOrganizationCounts returns the number of groups and services in an organization
+func getOrganizationCounts(client *iam.Client, orgID string) (groups, services int, err error) {
+	var groupBundle struct {
+		Total int `json:"total"`
+	}
+	query := url.Values{}
+	query.Set("orgID", orgID)
+	if _, err = iamRequest(client, http.MethodGet, "authorize/identity/Group?"+query.Encode(), "1", nil, &groupBundle); err != nil {
+		return
+	}
+	groups = groupBundle.Total
+
+	foundServices, _, err := client.Services.GetServices(&iam.GetServiceOptions{
+		OrganizationID: &orgID,
+	})
+	if err != nil {
+		return
+	}
+	if foundServices != nil {
+		services = len(*foundServices)
+	}
+	return
+}
+
+// getOrganizationUserCount returns the number of users in an organization. IAM does not
+// return a total, so this pages through all users of the organization
+func getOrganizationUserCount(client *iam.Client, orgID string) (int, error) {
+	users, _, err := client.Users.GetAllUsers(&iam.GetUserOptions{
+		OrganizationID: &orgID,
+	})
+	return len(users), err
+}
+
+func dataSourceIAMOrgTreeRead(_ context.Context, d *schema.ResourceData, meta interface{}) diag.Diagnostics {
+	config := meta.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	rootID := d.Get("organization_id").(string)
+	maxDepth := d.Get("max_depth").(int)
+	includeCounts := d.Get("include_counts").(bool)
+	includeUserCounts := d.Get("include_user_counts").(bool)
+
+	root, _, err := client.Organizations.GetOrganizationByID(rootID)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	type node struct {
+		org   iam.Organization
+		depth int
+	}
+	var orgIDs []string
+	var orgs []map[string]interface{}
+	seen := map[string]bool{}
+	queue := []node{{org: *root, depth: 0}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if seen[current.org.ID] { // Guard against cycles
+			continue
+		}
+		seen[current.org.ID] = true
+
+		entry := map[string]interface{}{
+			"id":            current.org.ID,
+			"name":          current.org.Name,
+			"display_name":  current.org.DisplayName,
+			"type":          current.org.Type,
+			"external_id":   current.org.ExternalID,
+			"active":        current.org.Active,
+			"parent_org_id": current.org.Parent.Value,
+			"depth":         current.depth,
+		}
+		if includeCounts {
+			groups, services, err := getOrganizationCounts(client, current.org.ID)
+			if err != nil {
+				return diag.FromErr(fmt.Errorf("counting resources of %s: %w", current.org.ID, err))
+			}
+			entry["group_count"] = groups
+			entry["service_count"] = services
+		}
+		if includeUserCounts {
+			users, err := getOrganizationUserCount(client, current.org.ID)
+			if err != nil {
+				return diag.FromErr(fmt.Errorf("counting users of %s: %w", current.org.ID, err))
+			}
+			entry["user_count"] = users
+		}
+		orgIDs = append(orgIDs, current.org.ID)
+		orgs = append(orgs, entry)
+
+		if maxDepth > 0 && current.depth >= maxDepth {
+			continue
+		}
+		children, err := getChildOrganizations(client, current.org.ID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		for _, child := range children {
+			queue = append(queue, node{org: child, depth: current.depth + 1})
+		}
+	}
+
+	d.SetId(rootID)
+	_ = d.Set("organization_ids", orgIDs)
+	_ = d.Set("organizations", orgs)
+
+	return diags
+}
@@ -0,0 +1,350 @@
+package hsdp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"unicode"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+const (
+	passwordLowercase    = "abcdefghijklmnopqrstuvwxyz"
+	passwordUppercase    = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordNumerics     = "0123456789"
+	passwordSpecialChars = "!@#$%^&*()-_=+[]{}:;,.?"
+)
+
+// passwordComplexity mirrors the complexity section of an IAM password policy
+type passwordComplexity struct {
+	MinLength       int
+	MaxLength       int
+	MinNumerics     int
+	MinUpperCase    int
+	MinLowerCase    int
+	MinSpecialChars int
+}
+
+func dataSourceIAMPasswordPolicyCheck() *schema.Resource {
+	return &schema.Resource{
+		ReadContext: dataSourceIAMPasswordPolicyCheckRead,
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ExactlyOneOf: []string{"organization_id", "complexity"},
+			},
+			"complexity": {
+				Type:     schema.TypeList,
+				Optional: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"min_length": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  8,
+						},
+						"max_length": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  16,
+						},
+						"min_numerics": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  1,
+						},
+						"min_uppercase": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  1,
+						},
+						"min_lowercase": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  1,
+						},
+						"min_special_chars": {
+							Type:     schema.TypeInt,
+							Optional: true,
+							Default:  1,
+						},
+					},
+				},
+			},
+			"passwords": {
+				Type:      schema.TypeList,
+				Optional:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"generate": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      0,
+				ValidateFunc: validation.IntBetween(0, 100),
+			},
+			"generate_length": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  0,
+			},
+			"seed": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"valid": {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			"results": {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"index": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"valid": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+						"failures": {
+							Type:     schema.TypeList,
+							Computed: true,
+							Elem:     &schema.Schema{Type: schema.TypeString},
+						},
+					},
+				},
+			},
+			"generated_passwords": {
+				Type:      schema.TypeList,
+				Computed:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// checkPassword returns the rules of the complexity the password violates
+func checkPassword(password string, c passwordComplexity) []string {
+	var failures []string
+	var length, numerics, upper, lower, special int
+
+	// IAM only counts ASCII characters towards the character classes, other
+	// characters count towards the length only
+	for _, r := range password {
+		length++
+		switch {
+		case r >= '0' && r <= '9':
+			numerics++
+		case r >= 'A' && r <= 'Z':
+			upper++
+		case r >= 'a' && r <= 'z':
+			lower++
+		case r < unicode.MaxASCII && unicode.IsPrint(r) && r != ' ':
+			special++
+		}
+	}
+	if length < c.MinLength {
+		failures = append(failures, fmt.Sprintf("min_length: %d < %d", length, c.MinLength))
+	}
+	if c.MaxLength > 0 && length > c.MaxLength {
+		failures = append(failures, fmt.Sprintf("max_length: %d > %d", length, c.MaxLength))
+	}
+	if numerics < c.MinNumerics {
+		failures = append(failures, fmt.Sprintf("min_numerics: %d < %d", numerics, c.MinNumerics))
+	}
+	if upper < c.MinUpperCase {
+		failures = append(failures, fmt.Sprintf("min_uppercase: %d < %d", upper, c.MinUpperCase))
+	}
+	if lower < c.MinLowerCase {
+		failures = append(failures, fmt.Sprintf("min_lowercase: %d < %d", lower, c.MinLowerCase))
+	}
+	if special < c.MinSpecialChars {
+		failures = append(failures, fmt.Sprintf("min_special_chars: %d < %d", special, c.MinSpecialChars))
+	}
+	return failures
+}
+
+// seededReader is a deterministic byte stream derived from a secret seed
+type seededReader struct {
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func (s *seededReader) Read(p []byte) (int, error) {
+	for len(s.buf) < len(p) {
+		mac := hmac.New(sha256.New, s.seed)
+		var block [8]byte
+		binary.BigEndian.PutUint64(block[:], s.counter)
+		mac.Write(block[:])
+		s.buf = append(s.buf, mac.Sum(nil)...)
+		s.counter++
+	}
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	return n, nil
+}
+
+func randomIndex(random io.Reader, n int) (int, error) {
+	i, err := rand.Int(random, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+// generatePassword produces a password of the given length which satisfies the complexity
+func generatePassword(random io.Reader, c passwordComplexity, length int) (string, error) {
+	required := c.MinNumerics + c.MinUpperCase + c.MinLowerCase + c.MinSpecialChars
+	if length <= 0 {
+		length = 16
+		if length < c.MinLength {
+			length = c.MinLength
+		}
+		if c.MaxLength > 0 && length > c.MaxLength {
+			length = c.MaxLength
+		}
+	}
+	if length < required || length < c.MinLength || (c.MaxLength > 0 && length > c.MaxLength) {
+		return "", fmt.Errorf("cannot generate a password of length %d for policy (min=%d, max=%d, required characters=%d)",
+			length, c.MinLength, c.MaxLength, required)
+	}
+	var password []byte
+	pick := func(set string, count int) error {
+		for i := 0; i < count; i++ {
+			idx, err := randomIndex(random, len(set))
+			if err != nil {
+				return err
+			}
+			password = append(password, set[idx])
+		}
+		return nil
+	}
+	all := passwordLowercase + passwordUppercase + passwordNumerics + passwordSpecialChars
+	for _, p := range []struct {
+		set   string
+		count int
+	}{
+		{passwordNumerics, c.MinNumerics},
+		{passwordUppercase, c.MinUpperCase},
+		{passwordLowercase, c.MinLowerCase},
+		{passwordSpecialChars, c.MinSpecialChars},
+		{all, length - required},
+	} {
+		if err := pick(p.set, p.count); err != nil {
+			return "", err
+		}
+	}
+	// Shuffle so the required characters do not end up in fixed positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomIndex(random, i+1)
+		if err != nil {
+			return "", err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+	return string(password), nil
+}
+
+func dataSourceIAMPasswordPolicyCheckRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+	var complexity passwordComplexity
+	var id string
+
+	if orgID, ok := d.GetOk("organization_id"); ok {
+		client, err := config.IAMClient()
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		organizationID := orgID.(string)
+		policies, _, err := client.PasswordPolicies.GetPasswordPolicies(&iam.GetPasswordPolicyOptions{
+			OrganizationID: &organizationID,
+		})
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if policies == nil || len(*policies) == 0 {
+			return diag.FromErr(fmt.Errorf("no password policy found for organization '%s': %w", organizationID, ErrResourceNotFound))
+		}
+		policy := (*policies)[0]
+		complexity = passwordComplexity{
+			MinLength:       policy.Complexity.MinLength,
+			MaxLength:       policy.Complexity.MaxLength,
+			MinNumerics:     policy.Complexity.MinNumerics,
+			MinUpperCase:    policy.Complexity.MinUpperCase,
+			MinLowerCase:    policy.Complexity.MinLowerCase,
+			MinSpecialChars: policy.Complexity.MinSpecialChars,
+		}
+		id = policy.ID
+	} else {
+		for _, vi := range d.Get("complexity").([]interface{}) {
+			mVi := vi.(map[string]interface{})
+			complexity = passwordComplexity{
+				MinLength:       mVi["min_length"].(int),
+				MaxLength:       mVi["max_length"].(int),
+				MinNumerics:     mVi["min_numerics"].(int),
+				MinUpperCase:    mVi["min_uppercase"].(int),
+				MinLowerCase:    mVi["min_lowercase"].(int),
+				MinSpecialChars: mVi["min_special_chars"].(int),
+			}
+		}
+		id = fmt.Sprintf("inline-%d-%d-%d-%d-%d-%d", complexity.MinLength, complexity.MaxLength,
+			complexity.MinNumerics, complexity.MinUpperCase, complexity.MinLowerCase, complexity.MinSpecialChars)
+	}
+
+	valid := true
+	var results []map[string]interface{}
+	for i, p := range d.Get("passwords").([]interface{}) {
+		password, _ := p.(string)
+		failures := checkPassword(password, complexity)
+		if len(failures) > 0 {
+			valid = false
+		}
+		results = append(results, map[string]interface{}{
+			"index":    i,
+			"valid":    len(failures) == 0,
+			"failures": failures,
+		})
+	}
+
+	var generated []string
+	if count := d.Get("generate").(int); count > 0 {
+		// Data sources are read on every plan, only a seed keeps the passwords stable
+		seed := d.Get("seed").(string)
+		if seed == "" {
+			return diag.FromErr(fmt.Errorf("a seed is required to generate passwords"))
+		}
+		random := &seededReader{seed: []byte(seed)}
+		for i := 0; i < count; i++ {
+			password, err := generatePassword(random, complexity, d.Get("generate_length").(int))
+			if err != nil {
+				return diag.FromErr(err)
+			}
+			generated = append(generated, password)
+		}
+	}
+
+	d.SetId(id)
+	_ = d.Set("valid", valid)
+	_ = d.Set("results", results)
+	_ = d.Set("generated_passwords", generated)
+	return diags
+}
@@ -0,0 +1,57 @@
+package hsdp
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPassword(t *testing.T) {
+	complexity := passwordComplexity{
+		MinLength:       8,
+		MaxLength:       16,
+		MinNumerics:     1,
+		MinUpperCase:    1,
+		MinLowerCase:    1,
+		MinSpecialChars: 1,
+	}
+	assert.Empty(t, checkPassword("Secret#123", complexity))
+
+	failures := checkPassword("secret", complexity)
+	assert.Len(t, failures, 4)
+	assert.Contains(t, failures[0], "min_length")
+
+	failures = checkPassword("ThisIsWayTooLong#123", complexity)
+	assert.Equal(t, []string{"max_length: 20 > 16"}, failures)
+
+	// Non-ASCII letters do not count as upper or lower case characters
+	failures = checkPassword("ÄÖÜäöü#123", complexity)
+	assert.Equal(t, []string{"min_uppercase: 0 < 1", "min_lowercase: 0 < 1"}, failures)
+}
+
+func TestGeneratePassword(t *testing.T) {
+	complexity := passwordComplexity{
+		MinLength:       10,
+		MaxLength:       12,
+		MinNumerics:     2,
+		MinUpperCase:    2,
+		MinLowerCase:    2,
+		MinSpecialChars: 2,
+	}
+	for i := 0; i < 20; i++ {
+		password, err := generatePassword(rand.Reader, complexity, 0)
+		if !assert.Nil(t, err) {
+			return
+		}
+		assert.Len(t, password, 12)
+		assert.Empty(t, checkPassword(password, complexity))
+	}
+
+	first, _ := generatePassword(&seededReader{seed: []byte("seed")}, complexity, 10)
+	second, _ := generatePassword(&seededReader{seed: []byte("seed")}, complexity, 10)
+	assert.Equal(t, first, second)
+
+	_, err := generatePassword(rand.Reader, complexity, 6)
+	assert.NotNil(t, err)
+}
@@ -26,21 +26,10 @@ func dataSourceIAMPermissionsRead(ctx context.Context, d *schema.ResourceData, m
 
 	var diags diag.Diagnostics
 
-	client, err := config.IAMClient()
+	permissions, err := config.IAMPermissionCatalog()
 	if err != nil {
 		return diag.FromErr(err)
 	}
-
-	resp, _, err := client.Permissions.GetPermissions(nil) // Get all permissions
-
-	if err != nil {
-		return diag.FromErr(err)
-	}
-	permissions := make([]string, 0)
-
-	for _, p := range *resp {
-		permissions = append(permissions, p.Name)
-	}
 	d.SetId("permissions")
 	_ = d.Set("permissions", permissions)
 
@@ -19,4 +19,5 @@ var (
 	ErrMissingOrganizationID    = errors.New("missing organization ID")
 	ErrMissingIAMCredentials    = errors.New("missing IAM credentials in the hsdp provider block. Add an IAM service identity or ORG admin with proper permissions")
 	ErrMissingUAACredentials    = errors.New("missing/invalid UAA credentials in the hsdp provider block")
+	ErrMissingCartelCredentials = errors.New("missing Cartel credentials in the hsdp provider block")
 )
@@ -0,0 +1,140 @@
+package hsdp
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+)
+
+// FunctionBackend is an execution plane for functions. A code registers a Docker image under
+// a unique name, schedules run the code with an encrypted siderite payload
+type FunctionBackend interface {
+	// CreateCode registers the image under the code name
+	CreateCode(name, image string) (*FunctionCode, error)
+	// GetCode returns the code, or nil when it does not exist
+	GetCode(codeID string) (*FunctionCode, error)
+	// UpdateCode stores changes to an existing code, e.g. a new image
+	UpdateCode(code FunctionCode) error
+	// DeleteCode removes the code together with its schedules
+	DeleteCode(codeID string) error
+	// CreateSchedule schedules a code to run on the cluster
+	CreateSchedule(schedule FunctionSchedule) (*FunctionSchedule, error)
+	// GetSchedules returns the schedules of the code
+	GetSchedules(codeName string) ([]FunctionSchedule, error)
+	// CancelSchedule removes a schedule
+	CancelSchedule(scheduleID string) error
+	// DockerLogin stores the registry credentials used to pull the images
+	DockerLogin(credentials FunctionDockerCredentials) error
+	// EncryptPayload encrypts a payload so only the cluster running the schedules can read it
+	EncryptPayload(payload []byte) (string, error)
+	// DecryptPayload decrypts a payload, or returns errPayloadNotDecryptable when the backend
+	// has no access to the key of the cluster
+	DecryptPayload(payload string) ([]byte, error)
+	// ClusterID returns the cluster which runs the schedules
+	ClusterID() string
+	// Endpoints returns the sync and async gateway URLs of the code
+	Endpoints(codeID string) (string, string)
+	// Gateway returns the settings which are passed to the siderite gateway in the payload
+	Gateway() FunctionGateway
+}
+
+// FunctionCode is a Docker image registered on a backend under a unique name
+type FunctionCode struct {
+	ID    string
+	Name  string
+	Image string
+}
+
+// FunctionSchedule runs a code on the cluster of the backend with a siderite payload. It is first
+// started at StartAt and then every RunEvery seconds
+type FunctionSchedule struct {
+	ID       string
+	CodeName string
+	Cluster  string
+	Payload  string
+	StartAt  *time.Time
+	RunEvery int
+	Timeout  int
+}
+
+// FunctionDockerCredentials are the registry credentials the backend pulls the images with
+type FunctionDockerCredentials struct {
+	Email         string
+	Username      string
+	Password      string
+	ServerAddress string
+}
+
+// errPayloadNotDecryptable is returned by backends which can not decrypt payloads
+var errPayloadNotDecryptable = errors.New("payload can not be decrypted without the cluster private key")
+
+// FunctionGateway holds the siderite gateway settings of a backend
+type FunctionGateway struct {
+	Token    string
+	Upstream string
+	AuthType string
+}
+
+// functionBackendFactory creates a backend from the backend credentials
+type functionBackendFactory func(config *Config, credentials map[string]string) (FunctionBackend, error)
+
+// functionBackends maps the backend type in the credentials to its implementation
+var functionBackends = map[string]functionBackendFactory{
+	"siderite": newSideriteBackend,
+	"ferrite":  newFerriteBackend,
+}
+
+// functionGateway reads the siderite gateway settings from the backend credentials
+func functionGateway(credentials map[string]string) FunctionGateway {
+	return FunctionGateway{
+		Token:    credentials["siderite_token"],
+		Upstream: credentials["siderite_upstream"],
+		AuthType: credentials["siderite_auth_type"],
+	}
+}
+
+// expandBackendCredentials returns the credentials of the backend block
+func expandBackendCredentials(d *schema.ResourceData) (map[string]string, error) {
+	backend, ok := d.Get("backend").([]interface{})
+	if !ok || len(backend) == 0 {
+		return nil, fmt.Errorf("expected array of 'backend' config")
+	}
+	backendResource, ok := backend[0].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected backend format")
+	}
+	configMap, ok := backendResource["credentials"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid or missing backend credentials")
+	}
+	credentials := make(map[string]string)
+	for k, v := range configMap {
+		if str, ok := v.(string); ok {
+			credentials[k] = str
+		}
+	}
+	return credentials, nil
+}
+
+// newFunctionBackend returns the backend selected by the 'type' in the backend credentials
+func newFunctionBackend(d *schema.ResourceData, m interface{}) (FunctionBackend, error) {
+	config := m.(*Config)
+	credentials, err := expandBackendCredentials(d)
+	if err != nil {
+		return nil, err
+	}
+	factory, ok := functionBackends[credentials["type"]]
+	if !ok {
+		types := make([]string, 0, len(functionBackends))
+		for t := range functionBackends {
+			types = append(types, fmt.Sprintf("'%s'", t))
+		}
+		sort.Strings(types)
+		return nil, fmt.Errorf("expected backend type of [%s]", strings.Join(types, " | "))
+	}
+	return factory(config, credentials)
+}
@@ -0,0 +1,218 @@
+package hsdp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const fakeFunctionBackendCluster = "local"
+
+// fakeFunctionBackendMu serializes access to the state directories of fake backends
+var fakeFunctionBackendMu sync.Mutex
+
+// fakeFunctionBackend keeps codes and schedules in a local directory, so the function resources
+// can be tested without Iron. Backends created with the same state_dir share their state, like
+// the backends the resources create on every call. Payloads are only encoded
+type fakeFunctionBackend struct {
+	stateDir string
+	baseURL  string
+	gateway  FunctionGateway
+}
+
+func init() {
+	functionBackends["fake"] = newFakeFunctionBackend
+}
+
+func newFakeFunctionBackend(_ *Config, credentials map[string]string) (FunctionBackend, error) {
+	b := &fakeFunctionBackend{
+		stateDir: credentials["state_dir"],
+		baseURL:  strings.TrimSuffix(credentials["base_url"], "/"),
+		gateway:  functionGateway(credentials),
+	}
+	if b.stateDir == "" {
+		return nil, fmt.Errorf("fake backend: missing state_dir")
+	}
+	for _, dir := range []string{"codes", "schedules"} {
+		if err := os.MkdirAll(filepath.Join(b.stateDir, dir), 0700); err != nil {
+			return nil, fmt.Errorf("fake backend: %w", err)
+		}
+	}
+	return b, nil
+}
+
+// newFakeFunctionBackendID returns an ID without dashes, as resource IDs are joined with dashes
+func newFakeFunctionBackendID() string {
+	return strings.Replace(uuid.New().String(), "-", "", -1)
+}
+
+func (b *fakeFunctionBackend) path(kind, id string) string {
+	return filepath.Join(b.stateDir, kind, id+".json")
+}
+
+func (b *fakeFunctionBackend) write(kind, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path(kind, id), data, 0600)
+}
+
+// read decodes the stored object into v, found is false when it does not exist
+func (b *fakeFunctionBackend) read(kind, id string, v interface{}) (bool, error) {
+	data, err := os.ReadFile(b.path(kind, id))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, json.Unmarshal(data, v)
+}
+
+func (b *fakeFunctionBackend) schedules() ([]FunctionSchedule, error) {
+	files, err := filepath.Glob(filepath.Join(b.stateDir, "schedules", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	schedules := make([]FunctionSchedule, 0, len(files))
+	for _, f := range files {
+		var schedule FunctionSchedule
+		if _, err := b.read("schedules", strings.TrimSuffix(filepath.Base(f), ".json"), &schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, schedule)
+	}
+	return schedules, nil
+}
+
+func (b *fakeFunctionBackend) CreateCode(name, image string) (*FunctionCode, error) {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	code := FunctionCode{
+		ID:    newFakeFunctionBackendID(),
+		Name:  name,
+		Image: image,
+	}
+	if err := b.write("codes", code.ID, code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (b *fakeFunctionBackend) GetCode(codeID string) (*FunctionCode, error) {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	var code FunctionCode
+	found, err := b.read("codes", codeID, &code)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &code, nil
+}
+
+func (b *fakeFunctionBackend) UpdateCode(code FunctionCode) error {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	var current FunctionCode
+	found, err := b.read("codes", code.ID, &current)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("code %s not found", code.ID)
+	}
+	current.Image = code.Image
+	return b.write("codes", code.ID, current)
+}
+
+func (b *fakeFunctionBackend) DeleteCode(codeID string) error {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	var code FunctionCode
+	found, err := b.read("codes", codeID, &code)
+	if err != nil || !found {
+		return err
+	}
+	schedules, err := b.schedules()
+	if err != nil {
+		return err
+	}
+	for _, s := range schedules {
+		if s.CodeName == code.Name {
+			_ = os.Remove(b.path("schedules", s.ID))
+		}
+	}
+	return os.Remove(b.path("codes", codeID))
+}
+
+func (b *fakeFunctionBackend) CreateSchedule(schedule FunctionSchedule) (*FunctionSchedule, error) {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	schedule.ID = newFakeFunctionBackendID()
+	if err := b.write("schedules", schedule.ID, schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+func (b *fakeFunctionBackend) GetSchedules(codeName string) ([]FunctionSchedule, error) {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	all, err := b.schedules()
+	if err != nil {
+		return nil, err
+	}
+	var schedules []FunctionSchedule
+	for _, s := range all {
+		if s.CodeName == codeName {
+			schedules = append(schedules, s)
+		}
+	}
+	return schedules, nil
+}
+
+func (b *fakeFunctionBackend) CancelSchedule(scheduleID string) error {
+	fakeFunctionBackendMu.Lock()
+	defer fakeFunctionBackendMu.Unlock()
+	err := os.Remove(b.path("schedules", scheduleID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *fakeFunctionBackend) DockerLogin(_ FunctionDockerCredentials) error {
+	return nil
+}
+
+func (b *fakeFunctionBackend) EncryptPayload(payload []byte) (string, error) {
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+func (b *fakeFunctionBackend) DecryptPayload(payload string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(payload)
+}
+
+func (b *fakeFunctionBackend) ClusterID() string {
+	return fakeFunctionBackendCluster
+}
+
+// Endpoints are empty unless base_url points to a test server
+func (b *fakeFunctionBackend) Endpoints(codeID string) (string, string) {
+	if b.baseURL == "" {
+		return "", ""
+	}
+	return fmt.Sprintf("%s/function/%s", b.baseURL, codeID),
+		fmt.Sprintf("%s/async-function/%s", b.baseURL, codeID)
+}
+
+func (b *fakeFunctionBackend) Gateway() FunctionGateway {
+	return b.gateway
+}
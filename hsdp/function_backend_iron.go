@@ -0,0 +1,222 @@
+package hsdp
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/philips-labs/ferrite/server"
+	"github.com/philips-software/go-hsdp-api/iron"
+)
+
+// ironBackend runs functions on HSDP Iron with the siderite gateway. Ferrite implements
+// the same API, so it shares this implementation after bootstrapping
+type ironBackend struct {
+	client     *iron.Client
+	config     *iron.Config
+	gateway    FunctionGateway
+	privateKey string
+}
+
+func newSideriteBackend(c *Config, credentials map[string]string) (FunctionBackend, error) {
+	ironConfig := iron.Config{
+		BaseURL:   credentials["base_url"],
+		Email:     credentials["email"],
+		Password:  credentials["password"],
+		Project:   credentials["project"],
+		ProjectID: credentials["project_id"],
+		Token:     credentials["token"],
+		UserID:    credentials["user_id"],
+		DebugLog:  c.DebugLog,
+		ClusterInfo: []iron.ClusterInfo{
+			{
+				ClusterID:   credentials["cluster_info_0_cluster_id"],
+				ClusterName: credentials["cluster_info_0_cluster_name"],
+				Pubkey:      credentials["cluster_info_0_pubkey"],
+				UserID:      credentials["cluster_info_0_user_id"],
+			},
+		},
+	}
+	client, err := iron.NewClient(&ironConfig)
+	if err != nil {
+		return nil, fmt.Errorf("iron.NewClient: %w", err)
+	}
+	return &ironBackend{
+		client:     client,
+		config:     &ironConfig,
+		gateway:    functionGateway(credentials),
+		privateKey: credentials["cluster_info_0_private_key"],
+	}, nil
+}
+
+func newFerriteBackend(c *Config, credentials map[string]string) (FunctionBackend, error) {
+	bootstrap, err := server.Bootstrap(credentials["base_url"], credentials["token"])
+	if err != nil {
+		return nil, fmt.Errorf("error bootstrapping ferrite: %w", err)
+	}
+	// Inject bootstrap data
+	bootstrapped := make(map[string]string)
+	for k, v := range credentials {
+		bootstrapped[k] = v
+	}
+	bootstrapped["project"] = bootstrap.ProjectID
+	bootstrapped["project_id"] = bootstrap.ProjectID
+	bootstrapped["cluster_info_0_cluster_id"] = bootstrap.ClusterID
+	bootstrapped["cluster_info_0_pubkey"] = bootstrap.PublicKey
+	return newSideriteBackend(c, bootstrapped)
+}
+
+// fromIronCode returns the backend independent view of an Iron code
+func fromIronCode(code *iron.Code) *FunctionCode {
+	return &FunctionCode{
+		ID:    code.ID,
+		Name:  code.Name,
+		Image: code.Image,
+	}
+}
+
+// toIronSchedule returns the Iron schedule for a schedule of a function
+func toIronSchedule(schedule FunctionSchedule) iron.Schedule {
+	return iron.Schedule{
+		ID:       schedule.ID,
+		CodeName: schedule.CodeName,
+		Cluster:  schedule.Cluster,
+		Payload:  schedule.Payload,
+		StartAt:  schedule.StartAt,
+		RunEvery: schedule.RunEvery,
+		Timeout:  schedule.Timeout,
+	}
+}
+
+// fromIronSchedule returns the backend independent view of an Iron schedule
+func fromIronSchedule(schedule iron.Schedule) FunctionSchedule {
+	return FunctionSchedule{
+		ID:       schedule.ID,
+		CodeName: schedule.CodeName,
+		Cluster:  schedule.Cluster,
+		Payload:  schedule.Payload,
+		StartAt:  schedule.StartAt,
+		RunEvery: schedule.RunEvery,
+		Timeout:  schedule.Timeout,
+	}
+}
+
+func (b *ironBackend) CreateCode(name, image string) (*FunctionCode, error) {
+	code, resp, err := b.client.Codes.CreateOrUpdateCode(iron.Code{
+		Name:      name,
+		Image:     image,
+		ProjectID: b.config.ProjectID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("code %s not found", image)
+	}
+	return fromIronCode(code), nil
+}
+
+func (b *ironBackend) GetCode(codeID string) (*FunctionCode, error) {
+	code, resp, err := b.client.Codes.GetCode(codeID)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code == nil || code.ID != codeID {
+		return nil, nil
+	}
+	return fromIronCode(code), nil
+}
+
+func (b *ironBackend) UpdateCode(code FunctionCode) error {
+	// Only the image changes, the other Iron fields are kept
+	current, _, err := b.client.Codes.GetCode(code.ID)
+	if err != nil {
+		return fmt.Errorf("GetCode(%s): %w", code.ID, err)
+	}
+	if current == nil {
+		return fmt.Errorf("code %s not found", code.ID)
+	}
+	current.Image = code.Image
+	_, resp, err := b.client.Codes.CreateOrUpdateCode(*current)
+	if err != nil {
+		return fmt.Errorf("CreateOrUpdateCode(%v): %w", *current, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to update code: %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (b *ironBackend) DeleteCode(codeID string) error {
+	// Deleting a code cascade deletes schedules as well
+	_, _, err := b.client.Codes.DeleteCode(codeID)
+	return err
+}
+
+func (b *ironBackend) CreateSchedule(schedule FunctionSchedule) (*FunctionSchedule, error) {
+	created, resp, err := b.client.Schedules.CreateSchedule(toIronSchedule(schedule))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("create schedule failed with code %d", resp.StatusCode)
+	}
+	result := fromIronSchedule(*created)
+	return &result, nil
+}
+
+func (b *ironBackend) GetSchedules(codeName string) ([]FunctionSchedule, error) {
+	schedules, _, err := b.client.Schedules.GetSchedulesWithCode(codeName)
+	if err != nil {
+		return nil, fmt.Errorf("GetSchedulesWithCode(%s): %w", codeName, err)
+	}
+	result := make([]FunctionSchedule, 0, len(*schedules))
+	for _, s := range *schedules {
+		result = append(result, fromIronSchedule(s))
+	}
+	return result, nil
+}
+
+func (b *ironBackend) CancelSchedule(scheduleID string) error {
+	_, _, err := b.client.Schedules.CancelSchedule(scheduleID)
+	return err
+}
+
+func (b *ironBackend) DockerLogin(credentials FunctionDockerCredentials) error {
+	ok, _, err := b.client.Codes.DockerLogin(iron.DockerCredentials{
+		Email:         credentials.Email,
+		Username:      credentials.Username,
+		Password:      credentials.Password,
+		ServerAddress: credentials.ServerAddress,
+	})
+	if !ok {
+		return fmt.Errorf("invalid docker credentials: %w", err)
+	}
+	return nil
+}
+
+func (b *ironBackend) EncryptPayload(payload []byte) (string, error) {
+	return iron.EncryptPayload([]byte(b.config.ClusterInfo[0].Pubkey), payload)
+}
+
+func (b *ironBackend) DecryptPayload(payload string) ([]byte, error) {
+	if b.privateKey == "" {
+		return nil, errPayloadNotDecryptable
+	}
+	return iron.DecryptPayload([]byte(b.privateKey), payload)
+}
+
+func (b *ironBackend) ClusterID() string {
+	return b.config.ClusterInfo[0].ClusterID
+}
+
+func (b *ironBackend) Endpoints(codeID string) (string, string) {
+	return fmt.Sprintf("https://%s/function/%s", b.gateway.Upstream, codeID),
+		fmt.Sprintf("https://%s/async-function/%s", b.gateway.Upstream, codeID)
+}
+
+func (b *ironBackend) Gateway() FunctionGateway {
+	return b.gateway
+}
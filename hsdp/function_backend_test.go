@@ -0,0 +1,57 @@
+package hsdp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/philips-software/go-hsdp-api/iron"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFunctionBackend(t *testing.T) {
+	r := resourceFunction()
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"name":         "backup",
+		"docker_image": "alpine:latest",
+		"backend": []interface{}{
+			map[string]interface{}{
+				"credentials": map[string]interface{}{
+					"type": "docker",
+				},
+			},
+		},
+	})
+	_, err := newFunctionBackend(d, &Config{})
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "'ferrite'")
+		assert.Contains(t, err.Error(), "'siderite'")
+	}
+}
+
+func TestIronScheduleConversion(t *testing.T) {
+	startAt := time.Now()
+	schedule := FunctionSchedule{
+		ID:       "id",
+		CodeName: "backup-abc",
+		Cluster:  "cluster",
+		Payload:  "payload",
+		StartAt:  &startAt,
+		RunEvery: 3600,
+		Timeout:  60,
+	}
+	converted := toIronSchedule(schedule)
+	assert.Equal(t, iron.Schedule{
+		ID:       "id",
+		CodeName: "backup-abc",
+		Cluster:  "cluster",
+		Payload:  "payload",
+		StartAt:  &startAt,
+		RunEvery: 3600,
+		Timeout:  60,
+	}, converted)
+	assert.Equal(t, schedule, fromIronSchedule(converted))
+
+	assert.Equal(t, &FunctionCode{ID: "id", Name: "backup-abc", Image: "alpine:latest"},
+		fromIronCode(&iron.Code{ID: "id", Name: "backup-abc", Image: "alpine:latest", Rev: 2}))
+}
@@ -0,0 +1,195 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	siderite "github.com/philips-labs/siderite/models"
+)
+
+// scheduleMissingField is true when the schedule of the function itself was removed outside of
+// Terraform. The plan then shows it flipping back to false, which recreates the schedule
+const scheduleMissingField = "schedule_missing"
+
+// decodedSchedule is a schedule of a function as found on the backend. Kind is the task
+// type of the schedule: cron, sync, async or schedule (run_every)
+type decodedSchedule struct {
+	ID       string
+	Kind     string
+	Schedule string
+	RunEvery int
+	Timeout  int
+	// Payload is nil when the backend can not decrypt it, PayloadErr tells why
+	Payload    *siderite.Payload
+	PayloadErr error
+}
+
+// decodeSchedule unwraps the CRON payload of a schedule and decrypts the siderite payload
+func decodeSchedule(backend FunctionBackend, s FunctionSchedule) decodedSchedule {
+	decoded := decodedSchedule{
+		ID:       s.ID,
+		Kind:     "schedule",
+		RunEvery: s.RunEvery,
+		Timeout:  s.Timeout,
+	}
+	encrypted := s.Payload
+	var cronPayload siderite.CronPayload
+	if err := json.Unmarshal([]byte(s.Payload), &cronPayload); err == nil && cronPayload.EncryptedPayload != "" {
+		encrypted = cronPayload.EncryptedPayload
+		switch cronPayload.Type {
+		case "sync", "async":
+			decoded.Kind = cronPayload.Type
+		default:
+			decoded.Kind = "cron"
+			decoded.Schedule = cronPayload.Schedule
+			decoded.Timeout = cronPayload.Timeout
+		}
+	}
+	plain, err := backend.DecryptPayload(encrypted)
+	if err != nil {
+		decoded.PayloadErr = err
+		return decoded
+	}
+	var payload siderite.Payload
+	if err := json.Unmarshal(plain, &payload); err != nil {
+		decoded.PayloadErr = fmt.Errorf("decoding payload: %w", err)
+		return decoded
+	}
+	decoded.Payload = &payload
+	return decoded
+}
+
+// expectedScheduleKind returns the kind of the schedule which carries the configuration
+// of the function, for functions this is the sync schedule
+func expectedScheduleKind(d *schema.ResourceData) string {
+	if d.Get("schedule").(string) != "" {
+		return "cron"
+	}
+	if d.Get("run_every").(string) != "" {
+		return "schedule"
+	}
+	return "sync"
+}
+
+// payloadEnvironment returns the environment of a payload, never nil
+func payloadEnvironment(p *siderite.Payload) map[string]string {
+	if p.Env == nil {
+		return map[string]string{}
+	}
+	return p.Env
+}
+
+// readFunctionSchedules updates schedule, run_every, timeout, command and environment from the
+// schedules on the backend, so changes made outside Terraform show up in the plan. Trigger
+// schedules are compared with the resolved trigger blocks. A missing schedule of the function
+// is flagged in schedule_missing. Returns a warning when the configured private key can not
+// decrypt the payloads
+func readFunctionSchedules(config *Config, backend FunctionBackend, d *schema.ResourceData, schedules []FunctionSchedule) diag.Diagnostics {
+	var diags diag.Diagnostics
+	triggerIDs := make(map[string]string)
+	for name, v := range d.Get(triggerSchedulesField).(map[string]interface{}) {
+		triggerIDs[v.(string)] = name
+	}
+	// Resolve the triggers before the function fields are refreshed, as they inherit from them
+	expected := expandTriggers(d.Get(triggerField), d.Get("command"), d.Get("environment"), d.Get("timeout"))
+
+	kind := expectedScheduleKind(d)
+	var primary *decodedSchedule
+	triggers := make(map[string]decodedSchedule)
+	var payloadErr error
+	for _, s := range schedules {
+		decoded := decodeSchedule(backend, s)
+		if decoded.PayloadErr != nil && payloadErr == nil {
+			payloadErr = decoded.PayloadErr
+		}
+		if name, ok := triggerIDs[s.ID]; ok {
+			triggers[name] = decoded
+			continue
+		}
+		if primary == nil && decoded.Kind == kind {
+			primary = &decoded
+		}
+	}
+	// Without a private key in the credentials the check is skipped quietly, only a key which
+	// does not work is worth a warning on every refresh
+	if payloadErr != nil {
+		_, _ = config.Debug("payloads of code '%s' can not be decrypted, skipping command and environment: %v\n", d.Get("name"), payloadErr)
+	}
+	if payloadErr != nil && !errors.Is(payloadErr, errPayloadNotDecryptable) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "command and environment drift not checked",
+			Detail: fmt.Sprintf("The payloads of function '%s' can not be decrypted with the 'cluster_info_0_private_key' "+
+				"backend credential, so changes made outside of Terraform to command and environment are not detected: %v",
+				d.Get("name"), payloadErr),
+		})
+	}
+
+	_ = d.Set(scheduleMissingField, primary == nil)
+	switch {
+	case primary == nil:
+	case kind == "cron":
+		_ = d.Set("schedule", primary.Schedule)
+	case kind == "schedule":
+		if runEvery, _, err := calcRunEvery(d.Get("run_every").(string), ""); err != nil || runEvery != primary.RunEvery {
+			_ = d.Set("run_every", fmt.Sprintf("%ds", primary.RunEvery))
+		}
+	}
+	if primary != nil {
+		_ = d.Set("timeout", primary.Timeout)
+		if p := primary.Payload; p != nil {
+			if !reflect.DeepEqual(p.Cmd, functionCommand(d.Get("command"))) {
+				_ = d.Set("command", p.Cmd)
+			}
+			if !reflect.DeepEqual(payloadEnvironment(p), getEnvironment(d)) {
+				_ = d.Set("environment", p.Env)
+			}
+		}
+	}
+
+	// Triggers take the values found on the backend where they differ from the resolved
+	// configuration, which makes the reconcile replace their schedule
+	list, _ := d.Get(triggerField).([]interface{})
+	for _, raw := range list {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		decoded, ok := triggers[name]
+		if !ok {
+			continue
+		}
+		t := expected[name]
+		if decoded.Schedule != t.Schedule {
+			m["schedule"] = decoded.Schedule
+		}
+		if decoded.Timeout != t.Timeout {
+			m["timeout"] = decoded.Timeout
+		}
+		if p := decoded.Payload; p != nil {
+			if !reflect.DeepEqual(p.Cmd, t.Command) {
+				m["command"] = p.Cmd
+			}
+			if !reflect.DeepEqual(payloadEnvironment(p), t.Environment) {
+				m["environment"] = p.Env
+			}
+		}
+	}
+	_ = d.Set(triggerField, list)
+	return diags
+}
+
+// customizeFunctionScheduleDiff plans the recreation of a schedule of the function which was
+// removed outside of Terraform
+func customizeFunctionScheduleDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Id() == "" || !d.Get(scheduleMissingField).(bool) {
+		return nil
+	}
+	return d.SetNew(scheduleMissingField, false)
+}
@@ -0,0 +1,187 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	siderite "github.com/philips-labs/siderite/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionReadDetectsDrift(t *testing.T) {
+	r := resourceFunction()
+	meta := &Config{}
+	stateDir := t.TempDir()
+	raw := map[string]interface{}{
+		"name":         "report",
+		"docker_image": "alpine:latest",
+		"schedule":     "0 6 * * *",
+		"environment":  map[string]interface{}{"MODE": "full"},
+		"backend": []interface{}{
+			map[string]interface{}{
+				"credentials": map[string]interface{}{
+					"type":      "fake",
+					"state_dir": stateDir,
+				},
+			},
+		},
+		"trigger": []interface{}{
+			map[string]interface{}{"name": "hourly", "schedule": "0 * * * *"},
+		},
+	}
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) {
+		return
+	}
+	state, diags := r.Apply(context.Background(), nil, diff, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty(), "unexpected diff: %v", diff)
+
+	// Change the schedules behind the back of Terraform
+	b, _ := newFakeFunctionBackend(meta, map[string]string{"state_dir": stateDir})
+	backend := b.(*fakeFunctionBackend)
+	code, _ := backend.GetCode(strings.Split(state.ID, "-")[0])
+	if !assert.NotNil(t, code) {
+		return
+	}
+	schedules, _ := backend.GetSchedules(code.Name)
+	for _, s := range schedules {
+		var cronPayload siderite.CronPayload
+		_ = json.Unmarshal([]byte(s.Payload), &cronPayload)
+		plain, _ := base64.StdEncoding.DecodeString(cronPayload.EncryptedPayload)
+		var payload siderite.Payload
+		_ = json.Unmarshal(plain, &payload)
+		payload.Env["MODE"] = "tampered"
+		plain, _ = json.Marshal(payload)
+		cronPayload.EncryptedPayload = base64.StdEncoding.EncodeToString(plain)
+		if cronPayload.Schedule == "0 6 * * *" {
+			cronPayload.Schedule = "0 7 * * *"
+		}
+		jsonPayload, _ := json.Marshal(cronPayload)
+		s.Payload = string(jsonPayload)
+		assert.Nil(t, backend.write("schedules", s.ID, s))
+	}
+
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "0 7 * * *", state.Attributes["schedule"])
+	assert.Equal(t, "tampered", state.Attributes["environment.MODE"])
+	assert.Equal(t, "tampered", state.Attributes["trigger.0.environment.MODE"])
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) || !assert.NotNil(t, diff) {
+		return
+	}
+	assert.Equal(t, "0 6 * * *", diff.Attributes["schedule"].New)
+	assert.Equal(t, "full", diff.Attributes["environment.MODE"].New)
+
+	// Applying restores the schedules
+	state, diags = r.Apply(context.Background(), state, diff, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty(), "unexpected diff: %v", diff)
+	schedules, _ = backend.GetSchedules(code.Name)
+	assert.Len(t, schedules, 2)
+
+	// A code removed outside Terraform removes the resource
+	assert.Nil(t, backend.DeleteCode(code.ID))
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Nil(t, state)
+}
+
+// sealedBackend can not decrypt payloads, like the siderite backend without or with a wrong private key
+type sealedBackend struct {
+	*fakeFunctionBackend
+	err error
+}
+
+func (b sealedBackend) DecryptPayload(_ string) ([]byte, error) {
+	return nil, b.err
+}
+
+func TestFunctionReadRecreatesMissingSchedule(t *testing.T) {
+	r := resourceFunction()
+	meta := &Config{}
+	stateDir := t.TempDir()
+	raw := map[string]interface{}{
+		"name":         "api",
+		"docker_image": "alpine:latest",
+		"backend": []interface{}{
+			map[string]interface{}{
+				"credentials": map[string]interface{}{
+					"type":      "fake",
+					"state_dir": stateDir,
+				},
+			},
+		},
+	}
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) {
+		return
+	}
+	state, diags := r.Apply(context.Background(), nil, diff, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, "false", state.Attributes[scheduleMissingField])
+
+	b, _ := newFakeFunctionBackend(meta, map[string]string{"state_dir": stateDir})
+	backend := b.(*fakeFunctionBackend)
+	code, _ := backend.GetCode(strings.Split(state.ID, "-")[0])
+	if !assert.NotNil(t, code) {
+		return
+	}
+	schedules, _ := backend.GetSchedules(code.Name)
+	for _, s := range schedules {
+		assert.Nil(t, backend.CancelSchedule(s.ID))
+	}
+
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "true", state.Attributes[scheduleMissingField])
+	assert.Equal(t, "1800", state.Attributes["timeout"])
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) || !assert.NotNil(t, diff) {
+		return
+	}
+	assert.False(t, diff.RequiresNew())
+	assert.Equal(t, "false", diff.Attributes[scheduleMissingField].New)
+
+	state, diags = r.Apply(context.Background(), state, diff, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	schedules, _ = backend.GetSchedules(code.Name)
+	assert.Len(t, schedules, 2)
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty(), "unexpected diff: %v", diff)
+
+	// Without the private key the command and environment are not checked, quietly
+	d := r.Data(state)
+	diags = readFunctionSchedules(meta, sealedBackend{backend, errPayloadNotDecryptable}, d, schedules)
+	assert.Len(t, diags, 0)
+
+	// A private key which does not work is reported
+	diags = readFunctionSchedules(meta, sealedBackend{backend, errors.New("crypto/rsa: decryption error")}, d, schedules)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, diag.Warning, diags[0].Severity)
+		assert.Contains(t, diags[0].Detail, "cluster_info_0_private_key")
+		assert.Contains(t, diags[0].Detail, "decryption error")
+	}
+}
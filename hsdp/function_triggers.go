@@ -0,0 +1,251 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	siderite "github.com/philips-labs/siderite/models"
+)
+
+const (
+	triggerField          = "trigger"
+	triggerSchedulesField = "trigger_schedules"
+)
+
+// functionTrigger is a named CRON schedule of a function. Command and environment are
+// resolved against those of the function
+type functionTrigger struct {
+	Name        string
+	Schedule    string
+	Command     []string
+	Environment map[string]string
+	Timeout     int
+}
+
+func triggerSchema() *schema.Schema {
+	return &schema.Schema{
+		Type:     schema.TypeList,
+		Optional: true,
+		MaxItems: 20,
+		Elem: &schema.Resource{
+			Schema: map[string]*schema.Schema{
+				"name": {
+					Type:     schema.TypeString,
+					Required: true,
+				},
+				"schedule": {
+					Type:             schema.TypeString,
+					Required:         true,
+					ValidateDiagFunc: validateCron,
+				},
+				"command": {
+					Type:     schema.TypeList,
+					Optional: true,
+					Elem:     &schema.Schema{Type: schema.TypeString},
+				},
+				"environment": {
+					Type:      schema.TypeMap,
+					Optional:  true,
+					Sensitive: true,
+					Elem:      &schema.Schema{Type: schema.TypeString},
+				},
+				"timeout": {
+					Type:     schema.TypeInt,
+					Optional: true,
+				},
+			},
+		},
+	}
+}
+
+// expandTriggers resolves the trigger blocks against the command, environment and timeout of
+// the function. The environment of a trigger is laid over the function environment
+func expandTriggers(triggers, command, environment, timeout interface{}) map[string]functionTrigger {
+	baseEnvironment := make(map[string]string)
+	if env, ok := environment.(map[string]interface{}); ok {
+		for k, v := range env {
+			baseEnvironment[k], _ = v.(string)
+		}
+	}
+	expanded := make(map[string]functionTrigger)
+	list, _ := triggers.([]interface{})
+	for _, raw := range list {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		t := functionTrigger{
+			Name:        m["name"].(string),
+			Schedule:    m["schedule"].(string),
+			Command:     functionCommand(command),
+			Environment: make(map[string]string),
+			Timeout:     m["timeout"].(int),
+		}
+		if override, ok := m["command"].([]interface{}); ok && len(override) > 0 {
+			t.Command = functionCommand(override)
+		}
+		for k, v := range baseEnvironment {
+			t.Environment[k] = v
+		}
+		if env, ok := m["environment"].(map[string]interface{}); ok {
+			for k, v := range env {
+				t.Environment[k], _ = v.(string)
+			}
+		}
+		if t.Timeout == 0 {
+			t.Timeout, _ = timeout.(int)
+		}
+		expanded[t.Name] = t
+	}
+	return expanded
+}
+
+// createTriggerSchedule creates the CRON schedule of the trigger and returns its ID
+func createTriggerSchedule(backend FunctionBackend, codeName string, t functionTrigger) (string, error) {
+	encrypted, err := encryptFunctionPayload(backend, "cron", "sync", t.Command, t.Environment)
+	if err != nil {
+		return "", fmt.Errorf("trigger %s: %w", t.Name, err)
+	}
+	jsonPayload, _ := json.Marshal(siderite.CronPayload{
+		Schedule:         t.Schedule,
+		EncryptedPayload: encrypted,
+		Timeout:          t.Timeout,
+	})
+	startAt := time.Now().Add(aLongTime * time.Second)
+	created, err := backend.CreateSchedule(FunctionSchedule{
+		CodeName: codeName,
+		Payload:  string(jsonPayload),
+		Cluster:  backend.ClusterID(),
+		StartAt:  &startAt,
+		RunEvery: aLongTime,
+	})
+	if err != nil {
+		return "", fmt.Errorf("trigger %s: %w", t.Name, err)
+	}
+	return created.ID, nil
+}
+
+// reconcileTriggers brings the trigger schedules in line with the configuration. Only triggers
+// which were added, changed or lost their schedule are created, removed triggers are cancelled.
+// The returned map holds the schedule of each trigger, also when an error occurred halfway
+func reconcileTriggers(backend FunctionBackend, d *schema.ResourceData, codeName string) (map[string]string, diag.Diagnostics) {
+	var diags diag.Diagnostics
+
+	oldTriggers, newTriggers := d.GetChange(triggerField)
+	oldCommand, newCommand := d.GetChange("command")
+	oldEnvironment, newEnvironment := d.GetChange("environment")
+	oldTimeout, newTimeout := d.GetChange("timeout")
+	previous := expandTriggers(oldTriggers, oldCommand, oldEnvironment, oldTimeout)
+	desired := expandTriggers(newTriggers, newCommand, newEnvironment, newTimeout)
+
+	current := make(map[string]string)
+	oldIDs, _ := d.GetChange(triggerSchedulesField)
+	for k, v := range oldIDs.(map[string]interface{}) {
+		current[k], _ = v.(string)
+	}
+	existing := make(map[string]bool)
+	if d.Id() != "" {
+		schedules, err := backend.GetSchedules(codeName)
+		if err != nil {
+			return current, diag.FromErr(err)
+		}
+		for _, s := range schedules {
+			existing[s.ID] = true
+		}
+	}
+
+	names := make([]string, 0, len(desired))
+	for name := range desired {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := desired[name]
+		id := current[name]
+		if id != "" && existing[id] && reflect.DeepEqual(previous[name], t) {
+			continue
+		}
+		newID, err := createTriggerSchedule(backend, codeName, t)
+		if err != nil {
+			return current, append(diags, diag.FromErr(err)...)
+		}
+		current[name] = newID
+		if id != "" && existing[id] {
+			_ = backend.CancelSchedule(id)
+		}
+	}
+	for name, id := range current {
+		if _, ok := desired[name]; ok {
+			continue
+		}
+		if existing[id] {
+			if err := backend.CancelSchedule(id); err != nil {
+				return current, append(diags, diag.FromErr(fmt.Errorf("trigger %s: %w", name, err))...)
+			}
+		}
+		delete(current, name)
+	}
+	return current, diags
+}
+
+// triggerScheduleIDs returns the schedule IDs of the triggers in the state
+func triggerScheduleIDs(d *schema.ResourceData) map[string]bool {
+	ids := make(map[string]bool)
+	oldIDs, _ := d.GetChange(triggerSchedulesField)
+	for _, v := range oldIDs.(map[string]interface{}) {
+		if id, ok := v.(string); ok {
+			ids[id] = true
+		}
+	}
+	return ids
+}
+
+// customizeFunctionTriggersDiff rejects duplicate trigger names and plans a reconcile of the
+// triggers when a schedule went missing
+func customizeFunctionTriggersDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	seen := make(map[string]bool)
+	var duplicates []string
+	triggers, _ := d.Get(triggerField).([]interface{})
+	for _, raw := range triggers {
+		m, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := m["name"].(string)
+		if seen[name] {
+			duplicates = append(duplicates, name)
+		}
+		seen[name] = true
+	}
+	if len(duplicates) > 0 {
+		return fmt.Errorf("duplicate trigger name(s): %s", strings.Join(duplicates, ", "))
+	}
+	if d.Id() == "" || !d.NewValueKnown(triggerField) {
+		return nil
+	}
+	ids := d.Get(triggerSchedulesField).(map[string]interface{})
+	if len(seen) == 0 && len(ids) == 0 {
+		return nil
+	}
+	if len(ids) != len(seen) {
+		return d.SetNewComputed(triggerSchedulesField)
+	}
+	for _, field := range []string{triggerField, "command", "environment", "timeout"} {
+		if d.HasChange(field) {
+			return d.SetNewComputed(triggerSchedulesField)
+		}
+	}
+	for name := range seen {
+		if id, _ := ids[name].(string); id == "" {
+			return d.SetNewComputed(triggerSchedulesField)
+		}
+	}
+	return nil
+}
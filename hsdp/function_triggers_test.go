@@ -0,0 +1,98 @@
+package hsdp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandTriggers(t *testing.T) {
+	triggers := []interface{}{
+		map[string]interface{}{
+			"name":        "hourly",
+			"schedule":    "0 * * * *",
+			"command":     []interface{}{},
+			"environment": map[string]interface{}{"MODE": "incremental"},
+			"timeout":     0,
+		},
+		map[string]interface{}{
+			"name":        "nightly",
+			"schedule":    "0 3 * * *",
+			"command":     []interface{}{"/app/full"},
+			"environment": map[string]interface{}{},
+			"timeout":     7200,
+		},
+	}
+	expanded := expandTriggers(triggers, []interface{}{}, map[string]interface{}{"MODE": "full", "DB": "pg"}, 1800)
+	if assert.Len(t, expanded, 2) {
+		assert.Equal(t, []string{"/app/server"}, expanded["hourly"].Command)
+		assert.Equal(t, map[string]string{"MODE": "incremental", "DB": "pg"}, expanded["hourly"].Environment)
+		assert.Equal(t, 1800, expanded["hourly"].Timeout)
+		assert.Equal(t, []string{"/app/full"}, expanded["nightly"].Command)
+		assert.Equal(t, map[string]string{"MODE": "full", "DB": "pg"}, expanded["nightly"].Environment)
+		assert.Equal(t, 7200, expanded["nightly"].Timeout)
+	}
+}
+
+func TestFunctionTriggersReconcile(t *testing.T) {
+	r := resourceFunction()
+	meta := &Config{}
+	stateDir := t.TempDir()
+	raw := map[string]interface{}{
+		"name":         "backup",
+		"docker_image": "alpine:latest",
+		"backend": []interface{}{
+			map[string]interface{}{
+				"credentials": map[string]interface{}{
+					"type":      "fake",
+					"state_dir": stateDir,
+				},
+			},
+		},
+		"trigger": []interface{}{
+			map[string]interface{}{"name": "hourly", "schedule": "0 * * * *"},
+			map[string]interface{}{"name": "nightly", "schedule": "0 3 * * *"},
+		},
+	}
+	apply := func(state *terraform.InstanceState) *terraform.InstanceState {
+		diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+		if !assert.Nil(t, err) || diff == nil {
+			return state
+		}
+		newState, diags := r.Apply(context.Background(), state, diff, meta)
+		assert.False(t, diags.HasError(), "%v", diags)
+		return newState
+	}
+	state := apply(nil)
+	hourly := state.Attributes["trigger_schedules.hourly"]
+	nightly := state.Attributes["trigger_schedules.nightly"]
+	assert.NotEmpty(t, hourly)
+	assert.NotEmpty(t, nightly)
+
+	raw["trigger"] = []interface{}{
+		map[string]interface{}{"name": "hourly", "schedule": "0 * * * *"},
+		map[string]interface{}{"name": "nightly", "schedule": "0 4 * * *"},
+	}
+	state = apply(state)
+	assert.Equal(t, hourly, state.Attributes["trigger_schedules.hourly"])
+	assert.NotEqual(t, nightly, state.Attributes["trigger_schedules.nightly"])
+	assert.NotEmpty(t, state.Attributes["trigger_schedules.nightly"])
+
+	raw["trigger"] = []interface{}{
+		map[string]interface{}{"name": "hourly", "schedule": "0 * * * *"},
+	}
+	state = apply(state)
+	assert.Equal(t, hourly, state.Attributes["trigger_schedules.hourly"])
+	assert.Equal(t, "1", state.Attributes["trigger_schedules.%"])
+
+	// The sync and async schedules of the function are left alone
+	backend, _ := newFakeFunctionBackend(meta, map[string]string{"state_dir": stateDir})
+	code, _ := backend.GetCode(strings.Split(state.ID, "-")[0])
+	if assert.NotNil(t, code) {
+		schedules, _ := backend.GetSchedules(code.Name)
+		assert.Len(t, schedules, 3)
+	}
+}
@@ -0,0 +1,98 @@
+package hsdp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+// iamRequestOption customizes a raw IAM request before it is sent
+type iamRequestOption func(*http.Request)
+
+// withIfMatch sets the If-Match header used by IAM for optimistic locking
+func withIfMatch(version string) iamRequestOption {
+	return func(req *http.Request) {
+		if version != "" {
+			req.Header.Set("If-Match", version)
+		}
+	}
+}
+
+// iamRequest performs a call against an IDM endpoint which is not (yet)
+// covered by go-hsdp-api. It reuses the HTTP client and token of the
+// IAM client so retries and debug logging behave the same. The path may
+// include an encoded query string. The returned response is non-nil
+// whenever the server was reached, so it can be used together with tryIAMCall
+func iamRequest(client *iam.Client, method, path, apiVersion string, body, v interface{}, options ...iamRequestOption) (*iam.Response, error) {
+	var rawQuery string
+	if i := strings.Index(path, "?"); i >= 0 {
+		path, rawQuery = path[:i], path[i+1:]
+	}
+	u := client.BaseIDMURL()
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(path, "/")
+
+	var bodyReader io.Reader
+	if body != nil {
+		bodyBytes, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(bodyBytes)
+	}
+	req, err := http.NewRequest(method, u.String(), bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	// Mirror go-hsdp-api so error messages render the same
+	req.URL.Opaque = u.Path
+	req.URL.RawQuery = rawQuery
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if apiVersion != "" {
+		req.Header.Set("api-version", apiVersion)
+	}
+	if token := client.Token(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, option := range options {
+		option(req)
+	}
+
+	httpClient := client.HttpClient()
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	resp := &iam.Response{Response: httpResp}
+
+	data, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return resp, err
+	}
+	if httpResp.StatusCode < 200 || httpResp.StatusCode > 299 {
+		errResp := &iam.ErrorResponse{Response: httpResp}
+		_ = json.Unmarshal(data, errResp)
+		if errResp.Message == "" {
+			errResp.Message = strings.TrimSpace(string(data))
+		}
+		return resp, errResp
+	}
+	if v != nil && len(data) > 0 {
+		if err := json.Unmarshal(data, v); err != nil {
+			return resp, fmt.Errorf("decoding %s response: %w", path, err)
+		}
+	}
+	return resp, nil
+}
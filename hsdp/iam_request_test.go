@@ -0,0 +1,140 @@
+package hsdp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/philips-software/go-hsdp-api/iam"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIAMRequest(t *testing.T) {
+	var requestURI, apiVersion, ifMatch string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestURI = r.RequestURI
+		apiVersion = r.Header.Get("api-version")
+		ifMatch = r.Header.Get("If-Match")
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodDelete {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"responseCode":"404","responseMessage":"not found"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"foo"}`))
+	}))
+	defer server.Close()
+
+	client, err := iam.NewClient(nil, &iam.Config{
+		OAuth2ClientID: "client",
+		OAuth2Secret:   "secret",
+		IAMURL:         server.URL,
+		IDMURL:         server.URL,
+	})
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	var result struct {
+		ID string `json:"id"`
+	}
+	resp, err := iamRequest(client, http.MethodGet, "authorize/identity/Thing?name=bar", "2", nil, &result)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "foo", result.ID)
+	assert.Equal(t, "/authorize/identity/Thing?name=bar", requestURI)
+	assert.Equal(t, "2", apiVersion)
+
+	resp, err = iamRequest(client, http.MethodDelete, "authorize/identity/Thing/foo", "1", nil, nil, withIfMatch("W/\"1\""))
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "not found")
+	}
+	if assert.NotNil(t, resp) {
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	}
+	assert.Equal(t, "W/\"1\"", ifMatch)
+}
+
+// scimStub is an in-memory IAM endpoint which stores the posted resources per path
+type scimStub struct {
+	mu        sync.Mutex
+	resources map[string]map[string]interface{}
+	nextID    int
+}
+
+func (s *scimStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.resources == nil {
+		s.resources = make(map[string]map[string]interface{})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"responseCode":"404","responseMessage":"not found"}`))
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var resource map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&resource)
+		s.nextID++
+		id := fmt.Sprintf("id-%d", s.nextID)
+		resource["id"] = id
+		resource["meta"] = map[string]interface{}{"version": "W/\"1\""}
+		s.resources[r.URL.Path+"/"+id] = resource
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(resource)
+	case http.MethodGet:
+		resource, ok := s.resources[r.URL.Path]
+		if !ok {
+			notFound()
+			return
+		}
+		_ = json.NewEncoder(w).Encode(resource)
+	case http.MethodPut:
+		current, ok := s.resources[r.URL.Path]
+		if !ok {
+			notFound()
+			return
+		}
+		if r.Header.Get("If-Match") != current["meta"].(map[string]interface{})["version"] {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = w.Write([]byte(`{"responseCode":"412","responseMessage":"version mismatch"}`))
+			return
+		}
+		var resource map[string]interface{}
+		_ = json.NewDecoder(r.Body).Decode(&resource)
+		resource["meta"] = map[string]interface{}{"version": "W/\"2\""}
+		s.resources[r.URL.Path] = resource
+		_ = json.NewEncoder(w).Encode(resource)
+	case http.MethodDelete:
+		if _, ok := s.resources[r.URL.Path]; !ok {
+			notFound()
+			return
+		}
+		delete(s.resources, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// newTestIAMConfig returns a provider config with an IAM client talking to the handler
+func newTestIAMConfig(t *testing.T, handler http.Handler) *Config {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	client, err := iam.NewClient(nil, &iam.Config{
+		OAuth2ClientID: "client",
+		OAuth2Secret:   "secret",
+		IAMURL:         server.URL,
+		IDMURL:         server.URL,
+	})
+	if err != nil {
+		t.Fatalf("iam.NewClient: %v", err)
+	}
+	return &Config{iamClient: client}
+}
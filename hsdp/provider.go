@@ -175,6 +175,7 @@ func Provider(build string) *schema.Provider {
 			"hsdp_s3creds_policy":                   resourceS3CredsPolicy(),
 			"hsdp_container_host":                   resourceContainerHost(),
 			"hsdp_container_host_exec":              resourceContainerHostExec(),
+			"hsdp_container_host_compose":           resourceContainerHostCompose(),
 			"hsdp_metrics_autoscaler":               resourceMetricsAutoscaler(),
 			"hsdp_cdr_org":                          resourceCDROrg(),
 			"hsdp_cdr_subscription":                 resourceCDRSubscription(),
@@ -188,6 +189,7 @@ func Provider(build string) *schema.Provider {
 			"hsdp_edge_custom_cert":                 resourceEdgeCustomCert(),
 			"hsdp_edge_sync":                        resourceEdgeSync(),
 			"hsdp_function":                         resourceFunction(),
+			"hsdp_function_invocation":              resourceFunctionInvocation(),
 			"hsdp_notification_producer":            resourceNotificationProducer(),
 			"hsdp_notification_subscriber":          resourceNotificationSubscriber(),
 			"hsdp_notification_topic":               resourceNotificationTopic(),
@@ -204,6 +206,10 @@ func Provider(build string) *schema.Provider {
 			"hsdp_cdl_export_route":                 resourceCDLExportRoute(),
 			"hsdp_ai_workspace_compute_target":      resourceAIWorkspaceComputeTarget(),
 			"hsdp_ai_workspace":                     resourceAIWorkspace(),
+			"hsdp_iam_sms_gateway":                  resourceIAMSMSGateway(),
+			"hsdp_iam_sms_template":                 resourceIAMSMSTemplate(),
+			"hsdp_iam_role_sharing":                 resourceIAMRoleSharing(),
+			"hsdp_container_host_power_schedule":    resourceContainerHostPowerSchedule(),
 		},
 		DataSourcesMap: map[string]*schema.Resource{
 			"hsdp_iam_introspect":                    dataSourceIAMIntrospect(),
@@ -236,6 +242,7 @@ func Provider(build string) *schema.Provider {
 			"hsdp_cdl_research_study":                dataSourceCDLResearchStudy(),
 			"hsdp_cdl_research_studies":              dataSourceCDLResearchStudies(),
 			"hsdp_container_host_instances":          dataSourceContainerHostInstances(),
+			"hsdp_container_host_security_groups":    dataSourceContainerHostSecurityGroups(),
 			"hsdp_cdl_data_type_definitions":         dataSourceCDLDataTypeDefinitions(),
 			"hsdp_cdl_data_type_definition":          dataSourceCDLDataTypeDefinition(),
 			"hsdp_cdl_label_definition":              dataSourceCDLLabelDefinition(),
@@ -245,6 +252,10 @@ func Provider(build string) *schema.Provider {
 			"hsdp_ai_workspace":                      dataSourceAIWorkspace(),
 			"hsdp_iam_group":                         dataSourceIAMGroup(),
 			"hsdp_iam_role":                          dataSourceIAMRole(),
+			"hsdp_iam_email_template_preview":        dataSourceIAMEmailTemplatePreview(),
+			"hsdp_iam_password_policy_check":         dataSourceIAMPasswordPolicyCheck(),
+			"hsdp_iam_org_tree":                      dataSourceIAMOrgTree(),
+			"hsdp_iam_effective_permissions":         dataSourceIAMEffectivePermissions(),
 		},
 		ConfigureContextFunc: providerConfigure(build),
 	}
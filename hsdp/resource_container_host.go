@@ -1,7 +1,6 @@
 package hsdp
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"os"
@@ -9,10 +8,10 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/hashicorp/go-cty/cty"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
-	"github.com/loafoe/easyssh-proxy/v2"
 	"github.com/philips-software/go-hsdp-api/cartel"
 
 	"log"
@@ -79,6 +78,7 @@ func resourceContainerHost() *schema.Resource {
 		ReadContext:   resourceContainerHostRead,
 		UpdateContext: resourceContainerHostUpdate,
 		DeleteContext: resourceContainerHostDelete,
+		CustomizeDiff: customdiff.All(customizeContainerHostDiff, customizeContainerHostFilesDiff, customizeContainerHostReplaceDiff, validateSecurityGroupsDiff),
 
 		Timeouts: &schema.ResourceTimeout{
 			Create: schema.DefaultTimeout(30 * time.Minute),
@@ -94,30 +94,25 @@ func resourceContainerHost() *schema.Resource {
 			"instance_role": {
 				Type:     schema.TypeString,
 				Optional: true,
-				ForceNew: true,
 				Default:  "container-host",
 			},
 			"image": {
 				Type:     schema.TypeString,
 				Optional: true,
-				ForceNew: true,
 			},
 			"instance_type": {
 				Type:     schema.TypeString,
 				Optional: true,
-				ForceNew: true,
 				Default:  "m5.large",
 			},
 			"volume_type": {
 				Type:          schema.TypeString,
 				Optional:      true,
-				ForceNew:      true,
 				ConflictsWith: []string{"iops"},
 			},
 			"iops": {
 				Type:         schema.TypeInt,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validation.IntBetween(1, 4000),
 			},
 			"protect": {
@@ -125,24 +120,27 @@ func resourceContainerHost() *schema.Resource {
 				Optional: true,
 				Default:  false,
 			},
+			"power_state": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "running",
+				ValidateFunc: validation.StringInSlice([]string{"running", "stopped"}, false),
+			},
 			"encrypt_volumes": {
 				Type:     schema.TypeBool,
 				Default:  true,
 				Optional: true,
-				ForceNew: true,
 			},
 			"volumes": {
 				Type:         schema.TypeInt,
 				Default:      0,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validation.IntBetween(0, 6),
 			},
 			"volume_size": {
 				Type:         schema.TypeInt,
 				Default:      0,
 				Optional:     true,
-				ForceNew:     true,
 				ValidateFunc: validation.IntBetween(0, 16000),
 			},
 			"security_groups": {
@@ -175,6 +173,37 @@ func resourceContainerHost() *schema.Resource {
 				Optional: true,
 				Default:  false,
 			},
+			"certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_private_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			hostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			bastionHostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+			},
+			knownHostsFileField: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"keep_failed_instances": {
 				Type:     schema.TypeBool,
 				Optional: true,
@@ -191,6 +220,20 @@ func resourceContainerHost() *schema.Resource {
 				Optional: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
 			},
+			"command_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultCommandTimeout,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"command_timeouts": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
 			fileField: {
 				Type:     schema.TypeSet,
 				Optional: true,
@@ -209,8 +252,9 @@ func resourceContainerHost() *schema.Resource {
 							Required: true,
 						},
 						"permissions": {
-							Type:     schema.TypeString,
-							Optional: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringMatch(filePermissions, "must be an octal mode, e.g. 0644"),
 						},
 						"owner": {
 							Type:     schema.TypeString,
@@ -220,22 +264,56 @@ func resourceContainerHost() *schema.Resource {
 							Type:     schema.TypeString,
 							Optional: true,
 						},
+						"template_vars": {
+							Type:      schema.TypeMap,
+							Optional:  true,
+							Sensitive: true,
+							Elem:      &schema.Schema{Type: schema.TypeString},
+						},
+						"template_format": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice([]string{templateFormatGo, templateFormatHCL}, false),
+						},
 					},
 				},
 			},
+			fileChecksumsField: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			readinessCheckField: readinessCheckSchema(),
+			"verify_remote_files": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
 			"subnet_type": {
 				Type:          schema.TypeString,
 				Optional:      true,
 				Computed:      true,
-				ForceNew:      true,
 				ConflictsWith: []string{"subnet"},
 			},
 			"subnet": {
 				Type:     schema.TypeString,
-				ForceNew: true,
 				Optional: true,
 				Computed: true,
 			},
+			"blue_green": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"resize_in_place": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"host_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
 			"private_ip": {
 				Type:     schema.TypeString,
 				Computed: true,
@@ -301,38 +379,15 @@ func resourceContainerHostCreate(ctx context.Context, d *schema.ResourceData, m
 	}
 
 	tagName := d.Get("name").(string)
-	protect := d.Get("protect").(bool)
-	iops := d.Get("iops").(int)
-	encryptVolumes := d.Get("encrypt_volumes").(bool)
-	volumeSize := d.Get("volume_size").(int)
-	numberOfVolumes := d.Get("volumes").(int)
-	volumeType := d.Get("volume_type").(string)
-	instanceType := d.Get("instance_type").(string)
-	securityGroups := expandStringList(d.Get("security_groups").(*schema.Set).List())
-	userGroups := expandStringList(d.Get("user_groups").(*schema.Set).List())
-	instanceRole := d.Get("instance_role").(string)
-	subnetType := d.Get("subnet_type").(string)
 	bastionHost := d.Get("bastion_host").(string)
 	keepFailedInstances := d.Get("keep_failed_instances").(bool)
 	if bastionHost == "" {
 		bastionHost = client.BastionHost()
 	}
-	image := d.Get("image").(string)
 	user := d.Get("user").(string)
 	privateKey := d.Get("private_key").(string)
 	agent := d.Get("agent").(bool)
 
-	if subnetType == "" {
-		subnetType = "private"
-	}
-	subnet := d.Get("subnet").(string)
-	tagList := d.Get("tags").(map[string]interface{})
-	tags := make(map[string]string)
-	for t, v := range tagList {
-		if val, ok := v.(string); ok {
-			tags[t] = val
-		}
-	}
 	// Validation
 	if diags := validateContainerHostSchema(d); len(diags) > 0 {
 		return diags
@@ -349,9 +404,11 @@ func resourceContainerHostCreate(ctx context.Context, d *schema.ResourceData, m
 		return diags
 	}
 
-	if len(commands) > 0 || len(createFiles) > 0 {
+	_, hasReadinessCheck := expandReadinessCheck(d)
+	provision := len(commands) > 0 || len(createFiles) > 0 || hasReadinessCheck
+	if provision {
 		if user == "" && !agent {
-			return diag.FromErr(fmt.Errorf("'user' must be set when 'agent = false' and '%s' are set or 'file' blocks are present", commandsField))
+			return diag.FromErr(fmt.Errorf("'user' must be set when 'agent = false' and '%s', 'file' or '%s' blocks are present", commandsField, readinessCheckField))
 		}
 		if privateKey == "" && !agent {
 			return diag.FromErr(fmt.Errorf("no SSH 'private_key' was set and 'agent = false', authentication will fail after provisioning step"))
@@ -361,21 +418,7 @@ func resourceContainerHostCreate(ctx context.Context, d *schema.ResourceData, m
 		}
 	}
 
-	ch, resp, err := client.Create(tagName,
-		cartel.SecurityGroups(securityGroups...),
-		cartel.UserGroups(userGroups...),
-		cartel.VolumeType(volumeType),
-		cartel.IOPs(iops),
-		cartel.InstanceType(instanceType),
-		cartel.VolumesAndSize(numberOfVolumes, volumeSize),
-		cartel.VolumeEncryption(encryptVolumes),
-		cartel.Protect(protect),
-		cartel.InstanceRole(instanceRole),
-		cartel.SubnetType(subnetType),
-		cartel.Tags(tags),
-		cartel.InSubnet(subnet),
-		cartel.Image(image),
-	)
+	ch, resp, err := client.Create(tagName, containerHostOptions(d, expandTags(d))...)
 	instanceID := ""
 	ipAddress := ""
 	if err != nil {
@@ -439,25 +482,22 @@ func resourceContainerHostCreate(ctx context.Context, d *schema.ResourceData, m
 	})
 	// Collect SSH details
 	privateIP := ipAddress
-	ssh := &easyssh.MakeConfig{
-		User:   user,
-		Server: privateIP,
-		Port:   "22",
-		Proxy:  http.ProxyFromEnvironment,
-		Bastion: easyssh.DefaultConfig{
-			User:   user,
-			Server: bastionHost,
-			Port:   "22",
-		},
-	}
-	if privateKey != "" {
-		ssh.Key = privateKey
-		ssh.Bastion.Key = privateKey
+	ssh := expandSSHConfig(d, privateIP, bastionHost)
+
+	// Capture and pin the host keys before anything is sent to the host
+	if provision {
+		if err := pinHostKeys(ssh, d); err != nil {
+			if !keepFailedInstances {
+				_, _, _ = client.Destroy(tagName)
+				d.SetId("")
+			}
+			return diag.FromErr(fmt.Errorf("instance '%s': %w", instanceID, err))
+		}
 	}
 
-	// Check health of Docker daemon in case of 'container-host' role and file or commands are set
-	if (len(commands) > 0 || len(createFiles) > 0) && instanceRole == "container-host" {
-		if err := ensureContainerHostReady(ssh, config); err != nil {
+	// Check readiness of the host before files and commands are provisioned
+	if provision {
+		if err := waitForHostReady(ssh, config, d); err != nil {
 			if !keepFailedInstances {
 				_, _, _ = client.Destroy(tagName)
 				d.SetId("")
@@ -476,27 +516,79 @@ func resourceContainerHostCreate(ctx context.Context, d *schema.ResourceData, m
 			Summary:  "failed to copy all files",
 			Detail:   fmt.Sprintf("One or more files failed to copy: %v", err),
 		})
+	} else if checksums, err := fileChecksums(createFiles); err == nil {
+		_ = d.Set(fileChecksumsField, checksums)
 	}
 
 	// Run commands
-	var stdout, stderr string
-	var done bool
-	for i := 0; i < len(commands); i++ {
-		stdout, stderr, done, err = ssh.Run(commands[i], 5*time.Minute)
-		_, _ = config.Debug("command: %s\ndone: %t\nstdout:\n%s\nstderr:\n%s\n", commands[i], done, stdout, stderr)
-		if err != nil {
-			_, _ = config.Debug("error: %v\n", err)
-			_, _, _ = client.Destroy(tagName)
-			return append(diags, diag.FromErr(fmt.Errorf("command [%s]: %w", commands[i], err))...)
-		}
+	results, cmdDiags := runCommands(ssh, config, commands, commandOptions{
+		Timeouts: expandCommandTimeouts(d, "command_timeouts", len(commands)),
+	})
+	if cmdDiags.HasError() {
+		_, _, _ = client.Destroy(tagName)
+		return append(diags, cmdDiags...)
 	}
-	_ = d.Set("result", stdout)
+	_ = d.Set("result", lastStdout(results))
+	_ = d.Set("host_name", tagName)
 	d.SetId(instanceID)
+
+	if d.Get("power_state").(string) == "stopped" {
+		if err := setPowerState(ctx, client, tagName, "stopped", d.Timeout(schema.TimeoutCreate)); err != nil {
+			return append(diags, diag.FromErr(fmt.Errorf("stopping '%s' after provisioning: %w", tagName, err))...)
+		}
+	}
 	readDiags := resourceContainerHostRead(ctx, d, m)
 	return append(diags, readDiags...)
 }
 
-func ensureContainerHostReady(ssh *easyssh.MakeConfig, config *Config) error {
+// expandTags returns the configured tags of the host
+func expandTags(d *schema.ResourceData) map[string]string {
+	tags := make(map[string]string)
+	for t, v := range d.Get("tags").(map[string]interface{}) {
+		if val, ok := v.(string); ok {
+			tags[t] = val
+		}
+	}
+	return tags
+}
+
+// containerHostOptions returns the Cartel create options for the configured host
+func containerHostOptions(d *schema.ResourceData, tags map[string]string) []cartel.RequestOptionFunc {
+	subnetType := d.Get("subnet_type").(string)
+	if subnetType == "" {
+		subnetType = "private"
+	}
+	return []cartel.RequestOptionFunc{
+		cartel.SecurityGroups(expandStringList(d.Get("security_groups").(*schema.Set).List())...),
+		cartel.UserGroups(expandStringList(d.Get("user_groups").(*schema.Set).List())...),
+		cartel.VolumeType(d.Get("volume_type").(string)),
+		cartel.IOPs(d.Get("iops").(int)),
+		cartel.InstanceType(d.Get("instance_type").(string)),
+		cartel.VolumesAndSize(d.Get("volumes").(int), d.Get("volume_size").(int)),
+		cartel.VolumeEncryption(d.Get("encrypt_volumes").(bool)),
+		cartel.Protect(d.Get("protect").(bool)),
+		cartel.InstanceRole(d.Get("instance_role").(string)),
+		cartel.SubnetType(subnetType),
+		cartel.Tags(tags),
+		cartel.InSubnet(d.Get("subnet").(string)),
+		cartel.Image(d.Get("image").(string)),
+	}
+}
+
+// containerHostName returns the Cartel name of the host, which differs from
+// 'name' once the host was replaced using blue_green. A planned replacement
+// leaves host_name unknown, the current host is then the one in the state
+func containerHostName(d *schema.ResourceData) string {
+	if hostName := d.Get("host_name").(string); hostName != "" {
+		return hostName
+	}
+	if hostName, _ := d.GetChange("host_name"); hostName.(string) != "" {
+		return hostName.(string)
+	}
+	return d.Get("name").(string)
+}
+
+func ensureContainerHostReady(ssh *sshConfig, config *Config) error {
 	operation := func() error {
 		outStr, errStr, done, err := ssh.Run("docker volume ls") // This command should succeed
 		_, _ = config.Debug("ensureContainerHostReady: %t\nstdout:\n%s\nstderr:\n%s\n", done, outStr, errStr)
@@ -515,6 +607,46 @@ func ensureContainerHostReady(ssh *easyssh.MakeConfig, config *Config) error {
 	return nil
 }
 
+// validateSecurityGroupsDiff checks the security groups against the Cartel security groups
+// so typos surface during plan instead of after provisioning started
+func validateSecurityGroupsDiff(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.HasChange("security_groups") || !d.NewValueKnown("security_groups") {
+		return nil
+	}
+	config, ok := m.(*Config)
+	if !ok {
+		return nil
+	}
+	groups, err := config.CartelSecurityGroups()
+	if err != nil {
+		// Do not block plans when Cartel is unavailable, apply will tell
+		log.Printf("[WARN] skipping security group validation, Cartel security groups unavailable: %v", err)
+		return nil
+	}
+	known := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		known[g] = true
+	}
+	var unknown []string
+	for _, g := range expandStringList(d.Get("security_groups").(*schema.Set).List()) {
+		if g == "base" {
+			return fmt.Errorf("the 'base' security group is internal and should not be specified")
+		}
+		if known[g] {
+			continue
+		}
+		if suggestions := nearMatches(g, groups, 3); len(suggestions) > 0 {
+			unknown = append(unknown, fmt.Sprintf("%s (did you mean %s?)", g, strings.Join(suggestions, ", ")))
+			continue
+		}
+		unknown = append(unknown, g)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown security group(s): %s", strings.Join(unknown, "; "))
+	}
+	return nil
+}
+
 func validateContainerHostSchema(d *schema.ResourceData) diag.Diagnostics {
 	var diags diag.Diagnostics
 
@@ -539,65 +671,6 @@ func findInstanceByName(client *cartel.Client, name string) *cartel.InstanceDeta
 	return nil
 }
 
-func copyFiles(ssh *easyssh.MakeConfig, config *Config, createFiles []provisionFile) error {
-	for _, f := range createFiles {
-		if f.Source != "" {
-			src, srcErr := os.Open(f.Source)
-			if srcErr != nil {
-				_, _ = config.Debug("Failed to open source file %s: %v\n", f.Source, srcErr)
-				return srcErr
-			}
-			srcStat, statErr := src.Stat()
-			if statErr != nil {
-				_, _ = config.Debug("Failed to stat source file %s: %v\n", f.Source, statErr)
-				_ = src.Close()
-				return fmt.Errorf("copyFiles: %w", statErr)
-			}
-			err := ssh.WriteFile(src, srcStat.Size(), f.Destination)
-			if err != nil {
-				_, _ = config.Debug("Error copying %s to remote file %s:%s: %v\n", f.Source, ssh.Server, f.Destination, err)
-				return fmt.Errorf("copyFiles: %w", err)
-			}
-			_, _ = config.Debug("Copied %s to remote file %s:%s: %d bytes\n", f.Source, ssh.Server, f.Destination, srcStat.Size())
-			_ = src.Close()
-		} else {
-			buffer := bytes.NewBufferString(f.Content)
-			// Should we fail the complete provision on errors here?
-			err := ssh.WriteFile(buffer, int64(buffer.Len()), f.Destination)
-			if err != nil {
-				_, _ = config.Debug("Error copying content to remote file %s:%s: %v\n", ssh.Server, f.Destination, err)
-				return fmt.Errorf("copyFiles: %w", err)
-			}
-			_, _ = config.Debug("Created remote file %s:%s: %d bytes\n", ssh.Server, f.Destination, len(f.Content))
-		}
-		// Permissions change
-		if f.Permissions != "" {
-			outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chmod %s \"%s\"", f.Permissions, f.Destination))
-			_, _ = config.Debug("Permissions file %s:%s: %v %v\n", f.Destination, f.Permissions, outStr, errStr)
-			if err != nil {
-				return err
-				// Owner
-			}
-		}
-		if f.Owner != "" {
-			outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chown %s \"%s\"", f.Owner, f.Destination))
-			_, _ = config.Debug("Owner file %s:%s: %v %v\n", f.Destination, f.Owner, outStr, errStr)
-			if err != nil {
-				return err
-			}
-		}
-		// Group
-		if f.Group != "" {
-			outStr, errStr, _, err := ssh.Run(fmt.Sprintf("chgrp %s \"%s\"", f.Group, f.Destination))
-			_, _ = config.Debug("Group file %s:%s: %v %v\n", f.Destination, f.Group, outStr, errStr)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 func collectList(fieldName string, d *schema.ResourceData) ([]string, diag.Diagnostics) {
 	var diags diag.Diagnostics
 	list := d.Get(fieldName).([]interface{})
@@ -610,12 +683,14 @@ func collectList(fieldName string, d *schema.ResourceData) ([]string, diag.Diagn
 }
 
 type provisionFile struct {
-	Source      string
-	Content     string
-	Destination string
-	Permissions string
-	Owner       string
-	Group       string
+	Source         string
+	Content        string
+	Destination    string
+	Permissions    string
+	Owner          string
+	Group          string
+	TemplateVars   map[string]string
+	TemplateFormat string
 }
 
 func collectFilesToCreate(d *schema.ResourceData) ([]provisionFile, diag.Diagnostics) {
@@ -624,15 +699,7 @@ func collectFilesToCreate(d *schema.ResourceData) ([]provisionFile, diag.Diagnos
 	if v, ok := d.GetOk(fileField); ok {
 		vL := v.(*schema.Set).List()
 		for _, vi := range vL {
-			mVi := vi.(map[string]interface{})
-			file := provisionFile{
-				Source:      mVi["source"].(string),
-				Content:     mVi["content"].(string),
-				Destination: mVi["destination"].(string),
-				Permissions: mVi["permissions"].(string),
-				Owner:       mVi["owner"].(string),
-				Group:       mVi["group"].(string),
-			}
+			file := expandProvisionFile(vi.(map[string]interface{}))
 			if file.Source == "" && file.Content == "" {
 				diags = append(diags, diag.Diagnostic{
 					Severity: diag.Error,
@@ -671,23 +738,37 @@ func collectFilesToCreate(d *schema.ResourceData) ([]provisionFile, diag.Diagnos
 				}
 				_ = src.Close()
 			}
-			files = append(files, file)
+			rendered, err := renderFile(file)
+			if err != nil {
+				diags = append(diags, diag.Diagnostic{
+					Severity: diag.Error,
+					Summary:  "issue with template",
+					Detail:   fmt.Sprintf("file %s: %v", file.Destination, err),
+				})
+				continue
+			}
+			files = append(files, rendered)
 		}
 	}
 	return files, diags
 }
 
-func resourceContainerHostUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+func resourceContainerHostUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	config := m.(*Config)
 
 	var diags diag.Diagnostics
 
+	// The plan expects the new checksums, they are only stored once the files are copied,
+	// so files which are not copied are tried again on the next apply
+	previousChecksums, _ := d.GetChange(fileChecksumsField)
+	_ = d.Set(fileChecksumsField, previousChecksums)
+
 	client, err := config.CartelClient()
 	if err != nil {
 		return diag.FromErr(err)
 	}
 
-	tagName := d.Get("name").(string)
+	tagName := containerHostName(d)
 	ch, _, err := client.GetDetails(tagName)
 	if err != nil {
 		return diag.FromErr(err)
@@ -700,8 +781,15 @@ func resourceContainerHostUpdate(_ context.Context, d *schema.ResourceData, m in
 	if diags := validateContainerHostSchema(d); len(diags) > 0 {
 		return diags
 	}
+	// Only reached with blue_green, otherwise these changes force a new resource
+	if d.HasChanges(replacementFields...) {
+		diags := replaceContainerHost(ctx, d, config, client)
+		if diags.HasError() {
+			return diags
+		}
+		return append(diags, resourceContainerHostRead(ctx, d, m)...)
+	}
 	bastionHost := d.Get("bastion_host").(string)
-	user := d.Get("user").(string)
 	privateKey := d.Get("private_key").(string)
 	commandsAfterFileChanges := d.Get("commands_after_file_changes").(bool)
 	agent := d.Get("agent").(bool)
@@ -709,6 +797,18 @@ func resourceContainerHostUpdate(_ context.Context, d *schema.ResourceData, m in
 		bastionHost = client.BastionHost()
 	}
 
+	powerState := d.Get("power_state").(string)
+	if d.HasChanges("instance_type", "volumes", "volume_size", "iops") {
+		if err := resizeContainerHost(ctx, config, client, d); err != nil {
+			return diag.FromErr(fmt.Errorf("resizing '%s': %w", tagName, err))
+		}
+	}
+	if powerState == "running" && d.HasChange("power_state") {
+		if err := setPowerState(ctx, client, tagName, "running", d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return diag.FromErr(fmt.Errorf("starting '%s': %w", tagName, err))
+		}
+	}
+
 	if d.HasChange("tags") {
 		o, n := d.GetChange("tags")
 		change := generateTagChange(o, n)
@@ -774,51 +874,59 @@ func resourceContainerHostUpdate(_ context.Context, d *schema.ResourceData, m in
 	}
 	// Collect SSH details
 	privateIP := d.Get("private_ip").(string)
-	ssh := &easyssh.MakeConfig{
-		User:   user,
-		Server: privateIP,
-		Port:   "22",
-		Proxy:  http.ProxyFromEnvironment,
-		Bastion: easyssh.DefaultConfig{
-			User:   user,
-			Server: bastionHost,
-			Port:   "22",
-		},
-	}
-	if privateKey != "" {
-		if agent {
-			return diag.FromErr(fmt.Errorf("'agent' is enabled so not expecting a private key to be set"))
-		}
-		ssh.Key = privateKey
-		ssh.Bastion.Key = privateKey
+	if privateKey != "" && agent {
+		return diag.FromErr(fmt.Errorf("'agent' is enabled so not expecting a private key to be set"))
 	}
-	if d.HasChange("file") {
+	ssh := expandSSHConfig(d, privateIP, bastionHost)
+	if d.HasChanges("file", fileChecksumsField) && powerState == "stopped" {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "files not copied",
+			Detail:   fmt.Sprintf("'%s' is stopped so file changes were not provisioned", tagName),
+		})
+	} else if d.HasChanges("file", fileChecksumsField) {
 		createFiles, diags := collectFilesToCreate(d)
 		if len(diags) > 0 {
 			return diags
 		}
-		_, _ = config.Debug("about to copy %d files to remote\n", len(createFiles))
-		if err := copyFiles(ssh, config, createFiles); err != nil {
+		if err := pinHostKeys(ssh, d); err != nil {
+			return diag.FromErr(err)
+		}
+		expected, err := fileChecksums(createFiles)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		o, _ := d.GetChange(fileChecksumsField)
+		current := make(map[string]string)
+		for k, v := range o.(map[string]interface{}) {
+			current[k] = v.(string)
+		}
+		changed := changedFiles(createFiles, current, expected)
+		changed = append(changed, changedFileAttributes(d, createFiles, changed)...)
+		_, _ = config.Debug("about to copy %d of %d files to remote\n", len(changed), len(createFiles))
+		if err := copyFiles(ssh, config, changed); err != nil {
 			return diag.FromErr(fmt.Errorf("copying files to remote: %w", err))
 		}
-		if commandsAfterFileChanges {
+		_ = d.Set(fileChecksumsField, expected)
+		if commandsAfterFileChanges && len(changed) > 0 {
 			commands, diags := collectList(commandsField, d)
 			if len(diags) > 0 {
 				return diags
 			}
-			// Run commands
-			var stdout, stderr string
-			var done bool
-			var err error
-			for i := 0; i < len(commands); i++ {
-				stdout, stderr, done, err = ssh.Run(commands[i], 5*time.Minute)
-				_, _ = config.Debug("command: %s\ndone: %t\nstdout:\n%s\nstderr:\n%s\n", commands[i], done, stdout, stderr)
-				if err != nil {
-					_, _ = config.Debug("error: %v\n", err)
-					return append(diags, diag.FromErr(fmt.Errorf("command [%s]: %w", commands[i], err))...)
-				}
+			results, cmdDiags := runCommands(ssh, config, commands, commandOptions{
+				Timeouts: expandCommandTimeouts(d, "command_timeouts", len(commands)),
+				Env:      changedFilesEnv(changed),
+			})
+			if cmdDiags.HasError() {
+				return append(diags, cmdDiags...)
 			}
-			_ = d.Set("result", stdout)
+			_ = d.Set("result", lastStdout(results))
+		}
+	}
+	// Stop last, so resizing and provisioning above still reach the host
+	if powerState == "stopped" {
+		if err := setPowerState(ctx, client, tagName, "stopped", d.Timeout(schema.TimeoutUpdate)); err != nil {
+			return append(diags, diag.FromErr(fmt.Errorf("stopping '%s': %w", tagName, err))...)
 		}
 	}
 	return diags
@@ -834,7 +942,7 @@ func resourceContainerHostRead(_ context.Context, d *schema.ResourceData, m inte
 		return diag.FromErr(err)
 	}
 
-	tagName := d.Get("name").(string)
+	tagName := containerHostName(d)
 
 	if tagName == "" { // This is an import, find and set the tagName
 		instances, _, err := client.GetAllInstances()
@@ -863,9 +971,11 @@ func resourceContainerHostRead(_ context.Context, d *schema.ResourceData, m inte
 		return diag.FromErr(err)
 	}
 	if state != "succeeded" {
-		// Unless we have a succeeded deploy, taint the resource
-		d.SetId("")
-		return diags
+		// Unless we have a succeeded deploy or a stopped instance, taint the resource
+		if details, _, err := client.GetDetails(tagName); err != nil || details.State != "stopped" {
+			d.SetId("")
+			return diags
+		}
 	}
 	ch, _, err := client.GetDetails(tagName)
 	if err != nil {
@@ -874,7 +984,11 @@ func resourceContainerHostRead(_ context.Context, d *schema.ResourceData, m inte
 	if ch.InstanceID != d.Id() {
 		return diag.FromErr(ErrInstanceIDMismatch)
 	}
+	_ = d.Set("host_name", tagName)
 	_ = d.Set("protect", ch.Protection)
+	if ch.State == "running" || ch.State == "stopped" { // Ignore transitional states
+		_ = d.Set("power_state", ch.State)
+	}
 	_ = d.Set("volumes", len(ch.BlockDevices)-1) // -1 for the root volume
 	_ = d.Set("role", ch.Role)
 	_ = d.Set("launch_time", ch.LaunchTime)
@@ -889,13 +1003,68 @@ func resourceContainerHostRead(_ context.Context, d *schema.ResourceData, m inte
 	_ = d.Set("private_ip", ch.PrivateAddress)
 	_ = d.Set("public_ip", ch.PublicAddress)
 	_ = d.Set("subnet", ch.Subnet)
-	subnetType := "private"
-	if ch.PublicAddress != "" {
-		subnetType = "public"
-	}
-	_ = d.Set("subnet_type", subnetType)
+	_ = d.Set("subnet_type", containerHostSubnetType(*ch))
 	_ = d.Set("tags", normalizeTags(ch.Tags))
 
+	if d.Get("verify_remote_files").(bool) && ch.State == "running" {
+		diags = append(diags, verifyRemoteFiles(d, client.BastionHost())...)
+	}
+	if ch.State == "running" {
+		bastionHost := d.Get("bastion_host").(string)
+		if bastionHost == "" {
+			bastionHost = client.BastionHost()
+		}
+		ssh := expandSSHConfig(d, ch.PrivateAddress, bastionHost)
+		diags = append(diags, recheckHostReady(ssh, d)...)
+	}
+	return diags
+}
+
+// verifyRemoteFiles replaces the stored checksums with the ones found on the host,
+// so modified or deleted files show up in the plan
+func verifyRemoteFiles(d *schema.ResourceData, defaultBastion string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	checksums := d.Get(fileChecksumsField).(map[string]interface{})
+	if len(checksums) == 0 {
+		return diags
+	}
+	destinations := make([]string, 0, len(checksums))
+	for dest := range checksums {
+		destinations = append(destinations, dest)
+	}
+	bastionHost := d.Get("bastion_host").(string)
+	if bastionHost == "" {
+		bastionHost = defaultBastion
+	}
+	ssh := expandSSHConfig(d, d.Get("private_ip").(string), bastionHost)
+	if err := applyHostKeys(ssh, d); err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "remote files not verified",
+			Detail:   fmt.Sprintf("%v, the check is skipped until the next apply pins it", err),
+		})
+	}
+	remote, unreadable, err := remoteChecksums(ssh, destinations)
+	if err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "unable to verify remote files",
+			Detail:   err.Error(),
+		})
+	}
+	// Files which can not be read keep their stored checksum, they are not known to differ
+	for _, dest := range unreadable {
+		remote[dest] = checksums[dest].(string)
+	}
+	if len(unreadable) > 0 {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "unable to verify some remote files",
+			Detail:   fmt.Sprintf("no permission to read %s", strings.Join(unreadable, ", ")),
+		})
+	}
+	_ = d.Set(fileChecksumsField, remote)
 	return diags
 }
 
@@ -909,7 +1078,7 @@ func resourceContainerHostDelete(_ context.Context, d *schema.ResourceData, m in
 		return diag.FromErr(err)
 	}
 
-	tagName := d.Get("name").(string)
+	tagName := containerHostName(d)
 
 	ch, _, err := client.GetDetails(tagName)
 	if err != nil {
@@ -0,0 +1,567 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	composeFileName       = "docker-compose.yml"
+	defaultComposeCommand = "docker-compose"
+	composeServicesField  = "services"
+)
+
+var composeProjectName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+func resourceContainerHostCompose() *schema.Resource {
+	return &schema.Resource{
+		Description: `The ` + "`hsdp_container_host_compose`" + ` resource deploys a Docker Compose project on a Container Host.`,
+
+		CreateContext: resourceContainerHostComposeCreate,
+		ReadContext:   resourceContainerHostComposeRead,
+		UpdateContext: resourceContainerHostComposeUpdate,
+		DeleteContext: resourceContainerHostComposeDelete,
+		CustomizeDiff: customizeContainerHostComposeDiff,
+		Importer: &schema.ResourceImporter{
+			StateContext: importContainerHostCompose,
+		},
+
+		Timeouts: &schema.ResourceTimeout{
+			Create: schema.DefaultTimeout(15 * time.Minute),
+			Update: schema.DefaultTimeout(15 * time.Minute),
+			Delete: schema.DefaultTimeout(15 * time.Minute),
+		},
+		Schema: map[string]*schema.Schema{
+			"host": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"project_name": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringMatch(composeProjectName, "must consist of lowercase letters, digits, dashes and underscores"),
+			},
+			"directory": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			"compose_file": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"env_files": {
+				Type:      schema.TypeMap,
+				Optional:  true,
+				Sensitive: true,
+				Elem:      &schema.Schema{Type: schema.TypeString},
+			},
+			"compose_command": {
+				Type:     schema.TypeString,
+				Optional: true,
+				Default:  defaultComposeCommand,
+			},
+			"health_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      300,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"bastion_host": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"user": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"private_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"agent": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			"certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_private_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			hostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			bastionHostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			knownHostsFileField: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			composeServicesField: {
+				Type:     schema.TypeSet,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+		},
+	}
+}
+
+// composeServiceStatus is the state of the containers of a service as reported by docker ps
+type composeServiceStatus struct {
+	Containers int
+	Running    int
+	Completed  int
+	Unhealthy  int
+}
+
+// ready reports whether all containers of the service run, or completed successfully in case
+// of one-off tasks, and none is unhealthy or still starting
+func (s composeServiceStatus) ready() bool {
+	return s.Containers > 0 && s.Running+s.Completed == s.Containers && s.Unhealthy == 0
+}
+
+// composeServiceDefinitions returns the definition of each service of a compose file
+func composeServiceDefinitions(composeFile string) (map[string]interface{}, error) {
+	var definition struct {
+		Services map[string]interface{} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(composeFile), &definition); err != nil {
+		return nil, fmt.Errorf("parsing compose file: %w", err)
+	}
+	if len(definition.Services) == 0 {
+		return nil, fmt.Errorf("compose file defines no services")
+	}
+	return definition.Services, nil
+}
+
+// composeServices returns the sorted service names of a compose file
+func composeServices(composeFile string) ([]string, error) {
+	definitions, err := composeServiceDefinitions(composeFile)
+	if err != nil {
+		return nil, err
+	}
+	services := make([]string, 0, len(definitions))
+	for name := range definitions {
+		services = append(services, name)
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// composeEnvFiles returns the base names of the env_file entries of a service definition
+func composeEnvFiles(definition interface{}) []string {
+	service, ok := definition.(map[interface{}]interface{})
+	if !ok {
+		return nil
+	}
+	var entries []interface{}
+	switch envFile := service["env_file"].(type) {
+	case string:
+		entries = []interface{}{envFile}
+	case []interface{}:
+		entries = envFile
+	}
+	var names []string
+	for _, entry := range entries {
+		switch e := entry.(type) {
+		case string:
+			names = append(names, path.Base(e))
+		case map[interface{}]interface{}: // Long syntax with a path key
+			if p, ok := e["path"].(string); ok {
+				names = append(names, path.Base(p))
+			}
+		}
+	}
+	return names
+}
+
+// composeServicesToRoll returns the existing services which need to be recreated one at a time:
+// services whose definition changed, which use a changed env file or which are not running.
+// New services are started by the final up. A changed .env file is used for variable
+// substitution in the whole compose file, so all services are rolled
+func composeServicesToRoll(oldFile, newFile string, changedEnvFiles, running []string) ([]string, error) {
+	newDefinitions, err := composeServiceDefinitions(newFile)
+	if err != nil {
+		return nil, err
+	}
+	services, _ := composeServices(newFile)
+	oldDefinitions, err := composeServiceDefinitions(oldFile)
+	if err != nil { // e.g. after an import, nothing is known about the deployed services
+		return services, nil
+	}
+	changedEnv := make(map[string]bool)
+	for _, name := range changedEnvFiles {
+		if name == ".env" {
+			return services, nil
+		}
+		changedEnv[name] = true
+	}
+	isRunning := make(map[string]bool)
+	for _, service := range running {
+		isRunning[service] = true
+	}
+	var roll []string
+	for _, service := range services {
+		old, existed := oldDefinitions[service]
+		if !existed {
+			continue
+		}
+		changed := !reflect.DeepEqual(old, newDefinitions[service]) || !isRunning[service]
+		for _, name := range composeEnvFiles(newDefinitions[service]) {
+			changed = changed || changedEnv[name]
+		}
+		if changed {
+			roll = append(roll, service)
+		}
+	}
+	return roll, nil
+}
+
+// changedComposeServices returns the services to roll for the planned change
+func changedComposeServices(d *schema.ResourceData) ([]string, error) {
+	if d.HasChange("compose_command") {
+		return composeServices(d.Get("compose_file").(string))
+	}
+	oldFile, newFile := d.GetChange("compose_file")
+	o, n := d.GetChange("env_files")
+	oldEnv, newEnv := o.(map[string]interface{}), n.(map[string]interface{})
+	var changedEnvFiles []string
+	for name, content := range newEnv {
+		if oldEnv[name] != content {
+			changedEnvFiles = append(changedEnvFiles, name)
+		}
+	}
+	for name := range oldEnv {
+		if _, ok := newEnv[name]; !ok {
+			changedEnvFiles = append(changedEnvFiles, name)
+		}
+	}
+	o, _ = d.GetChange(composeServicesField)
+	running := expandStringList(o.(*schema.Set).List())
+	return composeServicesToRoll(oldFile.(string), newFile.(string), changedEnvFiles, running)
+}
+
+// composeStatusCommand lists the containers of the project as service|status lines
+func composeStatusCommand(project string) string {
+	return fmt.Sprintf(`docker ps -a --filter label=com.docker.compose.project=%s --format '{{.Label "com.docker.compose.service"}}|{{.Status}}'`, project)
+}
+
+// parseComposeStatus parses the output of composeStatusCommand. The status is e.g.
+// "Up 2 minutes (healthy)", "Up 5 seconds (health: starting)" or "Exited (1) 3 seconds ago"
+func parseComposeStatus(output string) map[string]composeServiceStatus {
+	status := make(map[string]composeServiceStatus)
+	for _, line := range strings.Split(output, "\n") {
+		parts := strings.SplitN(strings.TrimSpace(line), "|", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			continue
+		}
+		s := status[parts[0]]
+		s.Containers++
+		switch {
+		case strings.HasPrefix(parts[1], "Up"):
+			s.Running++
+		case strings.HasPrefix(parts[1], "Exited (0)"):
+			s.Completed++
+		}
+		if strings.Contains(parts[1], "(unhealthy)") || strings.Contains(parts[1], "(health: starting)") {
+			s.Unhealthy++
+		}
+		status[parts[0]] = s
+	}
+	return status
+}
+
+// notReadyServices returns the services which are not running and healthy
+func notReadyServices(services []string, status map[string]composeServiceStatus) []string {
+	var notReady []string
+	for _, service := range services {
+		if !status[service].ready() {
+			notReady = append(notReady, service)
+		}
+	}
+	return notReady
+}
+
+func composeDirectory(d *schema.ResourceData) string {
+	if dir := d.Get("directory").(string); dir != "" {
+		return dir
+	}
+	return path.Join("/home", d.Get("user").(string), "compose", d.Get("project_name").(string))
+}
+
+// composeFiles returns the compose file and env files to upload to the project directory
+func composeFiles(d *schema.ResourceData, dir string) ([]provisionFile, error) {
+	files := []provisionFile{{
+		Content:     d.Get("compose_file").(string),
+		Destination: path.Join(dir, composeFileName),
+		Permissions: "0600",
+	}}
+	for name, content := range d.Get("env_files").(map[string]interface{}) {
+		if name == "" || strings.Contains(name, "/") || name == composeFileName {
+			return nil, fmt.Errorf("invalid env file name '%s'", name)
+		}
+		files = append(files, provisionFile{
+			Content:     content.(string),
+			Destination: path.Join(dir, name),
+			Permissions: "0600",
+		})
+	}
+	return files, nil
+}
+
+func composeCommand(d *schema.ResourceData, dir, args string) string {
+	return fmt.Sprintf("cd %q && %s -p %s %s", dir, d.Get("compose_command").(string), d.Get("project_name").(string), args)
+}
+
+// waitForComposeServices polls the containers until the services are running and healthy
+func waitForComposeServices(ssh *sshConfig, config *Config, project string, services []string, timeout time.Duration) error {
+	operation := func() error {
+		stdout, stderr, _, err := ssh.Run(composeStatusCommand(project))
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, stderr)
+		}
+		notReady := notReadyServices(services, parseComposeStatus(stdout))
+		_, _ = config.Debug("compose project %s not ready: %v\n", project, notReady)
+		if len(notReady) > 0 {
+			return fmt.Errorf("services not healthy: %s", strings.Join(notReady, ", "))
+		}
+		return nil
+	}
+	b := backoff.NewExponentialBackOff()
+	b.MaxInterval = 15 * time.Second
+	b.MaxElapsedTime = timeout
+	return backoff.Retry(operation, b)
+}
+
+// deployCompose uploads the project files and brings the services up. The services in roll
+// are recreated first, one at a time, each waiting for the previous one to become healthy.
+// Each compose command may take up to timeout, e.g. to pull images
+func deployCompose(d *schema.ResourceData, config *Config, roll []string, timeout time.Duration) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	services, err := composeServices(d.Get("compose_file").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ssh, diags := containerHostSSHConfig(d, config)
+	if len(diags) > 0 {
+		return diags
+	}
+	if err := pinHostKeys(ssh, d); err != nil {
+		return diag.FromErr(err)
+	}
+	if err := ensureContainerHostReady(ssh, config); err != nil {
+		return diag.FromErr(fmt.Errorf("container host ready-ness check failed: %w", err))
+	}
+	dir := composeDirectory(d)
+	files, err := composeFiles(d, dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if _, stderr, _, err := ssh.Run(fmt.Sprintf("mkdir -p %q", dir)); err != nil {
+		return diag.FromErr(fmt.Errorf("creating %s: %w: %s", dir, err, stderr))
+	}
+	if err := copyFiles(ssh, config, files); err != nil {
+		return diag.FromErr(fmt.Errorf("copying files to remote: %w", err))
+	}
+
+	healthTimeout := time.Duration(d.Get("health_timeout").(int)) * time.Second
+	project := d.Get("project_name").(string)
+	var commands []string
+	for _, service := range roll {
+		commands = append(commands, composeCommand(d, dir, "up -d --no-deps "+service))
+	}
+	commands = append(commands, composeCommand(d, dir, "up -d --remove-orphans"))
+	for i, command := range commands {
+		_, runDiags := runCommands(ssh, config, []string{command}, commandOptions{Timeouts: []time.Duration{timeout}})
+		if runDiags.HasError() {
+			return append(diags, runDiags...)
+		}
+		waitFor := services
+		if i < len(roll) {
+			waitFor = roll[i : i+1]
+		}
+		if err := waitForComposeServices(ssh, config, project, waitFor, healthTimeout); err != nil {
+			return append(diags, diag.FromErr(fmt.Errorf("compose project %s: %w", project, err))...)
+		}
+	}
+	_ = d.Set("directory", dir)
+	_ = d.Set(composeServicesField, services)
+	return diags
+}
+
+func resourceContainerHostComposeCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	diags := deployCompose(d, config, nil, d.Timeout(schema.TimeoutCreate))
+	if diags.HasError() {
+		return diags
+	}
+	d.SetId(fmt.Sprintf("%s/%s", d.Get("host").(string), d.Get("project_name").(string)))
+	return diags
+}
+
+func resourceContainerHostComposeRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	if containerHostGone(config, d.Get("host").(string)) {
+		d.SetId("")
+		return diags
+	}
+	// After an import the connection details are only known from the configuration
+	if d.Get(hostKeyField).(string) == "" {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "compose services not verified",
+			Detail:   "the host key is not pinned yet, the check is skipped until the next apply pins it",
+		})
+	}
+	ssh, diags := containerHostSSHConfig(d, config)
+	if len(diags) > 0 {
+		return diags
+	}
+	if err := applyHostKeys(ssh, d); err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "compose services not verified",
+			Detail:   fmt.Sprintf("%v, the check is skipped until the next apply pins it", err),
+		})
+	}
+	stdout, stderr, _, err := ssh.Run(composeStatusCommand(d.Get("project_name").(string)))
+	if err != nil {
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  "unable to verify compose services",
+			Detail:   fmt.Sprintf("%v: %s", err, stderr),
+		})
+	}
+	// Only services which are up and healthy are recorded, others show up as drift
+	status := parseComposeStatus(stdout)
+	if len(status) == 0 { // The project is gone, e.g. the host was rebuilt
+		d.SetId("")
+		return diags
+	}
+	var running []string
+	for service, s := range status {
+		if s.ready() {
+			running = append(running, service)
+		}
+	}
+	_ = d.Set(composeServicesField, running)
+	return diags
+}
+
+func resourceContainerHostComposeUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	if !d.HasChanges("compose_file", "env_files", "compose_command", composeServicesField) {
+		var diags diag.Diagnostics
+		return diags
+	}
+	roll, err := changedComposeServices(d)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return deployCompose(d, config, roll, d.Timeout(schema.TimeoutUpdate))
+}
+
+// importContainerHostCompose imports a project by its {host}/{project_name} ID. The compose
+// file and credentials come from the configuration, the next apply deploys and pins the host keys
+func importContainerHostCompose(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || !composeProjectName.MatchString(parts[1]) {
+		return nil, fmt.Errorf("unexpected ID '%s', expected {host}/{project_name}", d.Id())
+	}
+	_ = d.Set("host", parts[0])
+	_ = d.Set("project_name", parts[1])
+	_ = d.Set("compose_command", defaultComposeCommand)
+	_ = d.Set("health_timeout", 300)
+	return []*schema.ResourceData{d}, nil
+}
+
+func resourceContainerHostComposeDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	ssh, diags := containerHostSSHConfig(d, config)
+	if len(diags) > 0 {
+		return diags
+	}
+	if err := pinHostKeys(ssh, d); err != nil {
+		return diag.FromErr(err)
+	}
+	dir := composeDirectory(d)
+	files, err := composeFiles(d, dir)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	cleanup := "rm -f"
+	for _, f := range files {
+		cleanup += fmt.Sprintf(" %q", f.Destination)
+	}
+	_, runDiags := runCommands(ssh, config, []string{
+		composeCommand(d, dir, "down --remove-orphans"),
+		cleanup,
+	}, commandOptions{Timeouts: []time.Duration{d.Timeout(schema.TimeoutDelete), d.Timeout(schema.TimeoutDelete)}})
+	if runDiags.HasError() {
+		return runDiags
+	}
+	d.SetId("")
+	return diags
+}
+
+// customizeContainerHostComposeDiff validates the compose file and plans the services it defines.
+// Services which are no longer running were dropped from the state by Read and show up as a change
+func customizeContainerHostComposeDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.NewValueKnown("compose_file") {
+		return d.SetNewComputed(composeServicesField)
+	}
+	services, err := composeServices(d.Get("compose_file").(string))
+	if err != nil {
+		return err
+	}
+	current := expandStringList(d.Get(composeServicesField).(*schema.Set).List())
+	sort.Strings(current)
+	if strings.Join(current, ",") == strings.Join(services, ",") {
+		return nil
+	}
+	return d.SetNew(composeServicesField, services)
+}
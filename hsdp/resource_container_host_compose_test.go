@@ -0,0 +1,102 @@
+package hsdp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComposeServices(t *testing.T) {
+	services, err := composeServices(`
+version: "3.8"
+services:
+  web:
+    image: nginx
+  db:
+    image: postgres
+`)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"db", "web"}, services)
+	}
+	_, err = composeServices("version: '3'\n")
+	assert.NotNil(t, err)
+	_, err = composeServices("services: [")
+	assert.NotNil(t, err)
+}
+
+func TestParseComposeStatus(t *testing.T) {
+	output := "web|Up 2 minutes (healthy)\n" +
+		"web|Up 5 seconds (health: starting)\n" +
+		"db|Up 3 minutes\n" +
+		"migrate|Exited (0) 2 minutes ago\n" +
+		"worker|Exited (1) 3 seconds ago\n" +
+		"garbage\n"
+	status := parseComposeStatus(output)
+	assert.Equal(t, 2, status["web"].Containers)
+	assert.False(t, status["web"].ready())
+	assert.True(t, status["db"].ready())
+	assert.True(t, status["migrate"].ready())
+	assert.False(t, status["worker"].ready())
+
+	notReady := notReadyServices([]string{"db", "missing", "web", "worker"}, status)
+	assert.Equal(t, []string{"missing", "web", "worker"}, notReady)
+}
+
+func TestComposeServicesToRoll(t *testing.T) {
+	oldFile := `
+services:
+  web:
+    image: nginx:1.20
+    env_file: ./web.env
+  db:
+    image: postgres
+  worker:
+    image: worker:1
+    env_file:
+      - path: worker.env
+`
+	newFile := `
+services:
+  web:
+    image: nginx:1.21
+    env_file: ./web.env
+  db:
+    image: postgres
+  worker:
+    image: worker:1
+    env_file:
+      - path: worker.env
+  cache:
+    image: redis
+`
+	running := []string{"db", "web", "worker"}
+	roll, err := composeServicesToRoll(oldFile, newFile, nil, running)
+	if assert.Nil(t, err) {
+		assert.Equal(t, []string{"web"}, roll)
+	}
+	roll, _ = composeServicesToRoll(oldFile, newFile, []string{"worker.env"}, running)
+	assert.Equal(t, []string{"web", "worker"}, roll)
+	roll, _ = composeServicesToRoll(oldFile, oldFile, nil, []string{"web", "worker"})
+	assert.Equal(t, []string{"db"}, roll)
+	roll, _ = composeServicesToRoll(oldFile, oldFile, []string{".env"}, running)
+	assert.Equal(t, []string{"db", "web", "worker"}, roll)
+	roll, _ = composeServicesToRoll("", newFile, nil, nil)
+	assert.Equal(t, []string{"cache", "db", "web", "worker"}, roll)
+	_, err = composeServicesToRoll(oldFile, "services: [", nil, running)
+	assert.NotNil(t, err)
+}
+
+func TestImportContainerHostCompose(t *testing.T) {
+	r := resourceContainerHostCompose()
+	d := r.TestResourceData()
+	d.SetId("10.0.0.1/app")
+	imported, err := r.Importer.StateContext(context.Background(), d, nil)
+	if assert.Nil(t, err) && assert.Len(t, imported, 1) {
+		assert.Equal(t, "10.0.0.1", imported[0].Get("host"))
+		assert.Equal(t, "app", imported[0].Get("project_name"))
+	}
+	d.SetId("10.0.0.1")
+	_, err = r.Importer.StateContext(context.Background(), d, nil)
+	assert.NotNil(t, err)
+}
@@ -4,23 +4,26 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
-	"net/http"
 	"time"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/loafoe/easyssh-proxy/v2"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
 )
 
 func resourceContainerHostExec() *schema.Resource {
 	return &schema.Resource{
 		Description: `The ` + "`hsdp_container_host_exec`" + ` resource implements the standard resource lifecycle but takes no further action.
-The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values that, when changed, will cause the resource to be replaced.`,
+The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values that, when changed, will cause the resource to be replaced.
+Other changes run the ` + "`update_commands`" + `, when set.`,
 
 		CreateContext: resourceContainerHostExecCreate,
-		Read:          resourceContainerHostExecRead,
-		Delete:        resourceContainerHostExecDelete,
+		ReadContext:   resourceContainerHostExecRead,
+		UpdateContext: resourceContainerHostExecUpdate,
+		DeleteContext: resourceContainerHostExecDelete,
 		SchemaVersion: 2,
+		CustomizeDiff: customdiff.All(customizeContainerHostFilesDiff, customizeContainerHostExecDiff),
 
 		Schema: map[string]*schema.Schema{
 			"triggers": {
@@ -42,55 +45,161 @@ The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values t
 			"user": {
 				Type:     schema.TypeString,
 				Required: true,
-				ForceNew: true,
 			},
 			"private_key": {
 				Type:      schema.TypeString,
 				Optional:  true,
-				ForceNew:  true,
 				Sensitive: true,
 			},
 			"agent": {
 				Type:     schema.TypeBool,
 				Optional: true,
-				ForceNew: true,
 				Default:  false,
 			},
+			"certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_user": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"bastion_private_key": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"bastion_certificate": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			hostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			bastionHostKeyField: {
+				Type:     schema.TypeString,
+				Optional: true,
+				Computed: true,
+				ForceNew: true,
+			},
+			knownHostsFileField: {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
 			"result": {
 				Type:     schema.TypeString,
 				Computed: true,
 			},
+			fileChecksumsField: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			commandsField: {
 				Type:     schema.TypeList,
 				MaxItems: 50,
 				Optional: true,
 				Elem:     &schema.Schema{Type: schema.TypeString},
-				ForceNew: true,
+			},
+			"update_commands": {
+				Type:     schema.TypeList,
+				MaxItems: 50,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"destroy_commands": {
+				Type:     schema.TypeList,
+				MaxItems: 50,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"command_timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      defaultCommandTimeout,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"command_timeouts": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+			"update_command_timeouts": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+			"destroy_command_timeouts": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem: &schema.Schema{
+					Type:         schema.TypeInt,
+					ValidateFunc: validation.IntAtLeast(0),
+				},
+			},
+			"continue_on_error": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  false,
+			},
+			commandResultsField: {
+				Type:     schema.TypeList,
+				Computed: true,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"command": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"exit_code": {
+							Type:     schema.TypeInt,
+							Computed: true,
+						},
+						"stdout": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"stderr": {
+							Type:     schema.TypeString,
+							Computed: true,
+						},
+						"timed_out": {
+							Type:     schema.TypeBool,
+							Computed: true,
+						},
+					},
+				},
 			},
 			fileField: {
 				Type:     schema.TypeSet,
 				Optional: true,
-				ForceNew: true,
 				Elem: &schema.Resource{
 					Schema: map[string]*schema.Schema{
 						"source": {
 							Type:     schema.TypeString,
 							Optional: true,
-							ForceNew: true,
 						},
 						"content": {
 							Type:     schema.TypeString,
 							Optional: true,
-							ForceNew: true,
 						},
 						"destination": {
 							Type:     schema.TypeString,
 							Required: true,
-							ForceNew: true,
 						},
 						"permissions": {
-							Type:     schema.TypeString,
-							Optional: true,
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringMatch(filePermissions, "must be an octal mode, e.g. 0644"),
 						},
 						"owner": {
 							Type:     schema.TypeString,
@@ -100,6 +209,17 @@ The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values t
 							Type:     schema.TypeString,
 							Optional: true,
 						},
+						"template_vars": {
+							Type:      schema.TypeMap,
+							Optional:  true,
+							Sensitive: true,
+							Elem:      &schema.Schema{Type: schema.TypeString},
+						},
+						"template_format": {
+							Type:         schema.TypeString,
+							Optional:     true,
+							ValidateFunc: validation.StringInSlice([]string{templateFormatGo, templateFormatHCL}, false),
+						},
 					},
 				},
 			},
@@ -109,21 +229,6 @@ The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values t
 
 func resourceContainerHostExecCreate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	config := m.(*Config)
-	client, err := config.CartelClient()
-	if err != nil {
-		return diag.FromErr(err)
-	}
-
-	var diags diag.Diagnostics
-
-	bastionHost := d.Get("bastion_host").(string)
-	if bastionHost == "" {
-		bastionHost = client.BastionHost()
-	}
-	user := d.Get("user").(string)
-	privateKey := d.Get("private_key").(string)
-	host := d.Get("host").(string)
-	agent := d.Get("agent").(bool)
 
 	// Fetch files first before starting provisioning
 	createFiles, diags := collectFilesToCreate(d)
@@ -135,66 +240,210 @@ func resourceContainerHostExecCreate(_ context.Context, d *schema.ResourceData,
 	if len(diags) > 0 {
 		return diags
 	}
-	if len(commands) > 0 || len(createFiles) > 0 {
-		if user == "" {
-			return diag.FromErr(fmt.Errorf("user must be set when '%s' is specified", commandsField))
+	results, diags := containerHostExecProvision(d, config, createFiles, commands, expandCommandTimeouts(d, "command_timeouts", len(commands)))
+	if diags.HasError() {
+		return diags
+	}
+	if checksums, err := fileChecksums(createFiles); err == nil {
+		_ = d.Set(fileChecksumsField, checksums)
+	}
+	_ = d.Set(commandResultsField, flattenCommandResults(results))
+	_ = d.Set("result", lastStdout(results))
+	d.SetId(fmt.Sprintf("%d", rand.Int()))
+	return diags
+}
+
+func resourceContainerHostExecRead(_ context.Context, _ *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	return diags
+}
+
+func resourceContainerHostExecUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	// The plan expects the new checksums, they are only stored once the files are uploaded
+	// and the commands succeeded, so a failed update is tried again on the next apply
+	o, _ := d.GetChange(fileChecksumsField)
+	_ = d.Set(fileChecksumsField, o)
+
+	files, diags := collectFilesToCreate(d)
+	if len(diags) > 0 {
+		return diags
+	}
+	commands, diags := collectList("update_commands", d)
+	if len(diags) > 0 {
+		return diags
+	}
+	expected, err := fileChecksums(files)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	current := make(map[string]string)
+	for k, v := range o.(map[string]interface{}) {
+		current[k] = v.(string)
+	}
+	changed := changedFiles(files, current, expected)
+	changed = append(changed, changedFileAttributes(d, files, changed)...)
+	_, _ = config.Debug("about to copy %d of %d files to remote\n", len(changed), len(files))
+
+	results, diags := containerHostExecProvision(d, config, changed, commands, expandCommandTimeouts(d, "update_command_timeouts", len(commands)))
+	if diags.HasError() {
+		return diags
+	}
+	_ = d.Set(fileChecksumsField, expected)
+	if len(commands) > 0 {
+		_ = d.Set(commandResultsField, flattenCommandResults(results))
+		_ = d.Set("result", lastStdout(results))
+	}
+	return diags
+}
+
+func resourceContainerHostExecDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	commands, diags := collectList("destroy_commands", d)
+	if len(diags) > 0 {
+		return diags
+	}
+	if len(commands) > 0 {
+		// A host which is gone can not be cleaned up, it should not block the destroy
+		if reason := containerHostUnreachable(d, config); reason != "" {
+			d.SetId("")
+			return append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  "destroy_commands skipped",
+				Detail:   fmt.Sprintf("host %s: %s", d.Get("host").(string), reason),
+			})
+		}
+	}
+	_, diags = containerHostExecProvision(d, config, nil, commands, expandCommandTimeouts(d, "destroy_command_timeouts", len(commands)))
+	if diags.HasError() {
+		return diags
+	}
+	d.SetId("")
+	return diags
+}
+
+// containerHostGone reports whether Cartel no longer lists an instance with the private IP.
+// When Cartel can not be queried the host is assumed to exist
+func containerHostGone(config *Config, host string) bool {
+	client, err := config.CartelClient()
+	if err != nil || client == nil {
+		return false
+	}
+	instances, _, err := client.GetAllInstances()
+	if err != nil {
+		return false
+	}
+	for _, instance := range *instances {
+		if instance.PrivateAddress == host && instance.State != "terminated" {
+			return false
 		}
-		if privateKey == "" && !agent {
-			return diag.FromErr(fmt.Errorf("no SSH 'private_key' was set and 'agent' is 'false', authentication will fail after provisioning step"))
+	}
+	return true
+}
+
+// containerHostUnreachable returns why the host can not be reached, or an empty string when
+// it can. Host key mismatches are not a reason, those must still fail the operation
+func containerHostUnreachable(d *schema.ResourceData, config *Config) string {
+	if containerHostGone(config, d.Get("host").(string)) {
+		return "the instance no longer exists in Cartel"
+	}
+	ssh, diags := containerHostSSHConfig(d, config)
+	if len(diags) > 0 {
+		return ""
+	}
+	if err := pinHostKeys(ssh, d); err != nil {
+		if isHostKeyError(err) {
+			return ""
 		}
+		return err.Error()
 	}
-	// Collect SSH details
-	privateIP := host
-	ssh := &easyssh.MakeConfig{
-		User:   user,
-		Server: privateIP,
-		Port:   "22",
-		Proxy:  http.ProxyFromEnvironment,
-		Bastion: easyssh.DefaultConfig{
-			User:   user,
-			Server: bastionHost,
-			Port:   "22",
-		},
+	if _, err := connectionPool.get(ssh); err != nil && !isHostKeyError(err) {
+		return err.Error()
+	}
+	return ""
+}
+
+// customizeContainerHostExecDiff replaces the resource when files or commands change and
+// there are no update_commands to handle the change in place
+func customizeContainerHostExecDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	if updateCommands, ok := d.GetOk("update_commands"); ok && len(updateCommands.([]interface{})) > 0 {
+		return nil
 	}
-	if privateKey != "" {
-		if agent {
-			return diag.FromErr(fmt.Errorf("'agent' is enabled so not expecting a private key to be set"))
+	for _, key := range []string{commandsField, fileField, fileChecksumsField} {
+		if d.HasChange(key) {
+			if err := d.ForceNew(key); err != nil {
+				return err
+			}
 		}
-		ssh.Key = privateKey
-		ssh.Bastion.Key = privateKey
+	}
+	return nil
+}
+
+// containerHostExecProvision copies the files and runs the commands on the host. It is shared
+// by create, update and destroy so all of them validate, connect and fail in the same way
+func containerHostExecProvision(d *schema.ResourceData, config *Config, files []provisionFile, commands []string, timeouts []time.Duration) ([]commandResult, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if len(commands) == 0 && len(files) == 0 {
+		return nil, diags
+	}
+	ssh, diags := containerHostSSHConfig(d, config)
+	if len(diags) > 0 {
+		return nil, diags
+	}
+
+	// Capture and pin the host keys before anything is sent to the host
+	if err := pinHostKeys(ssh, d); err != nil {
+		return nil, diag.FromErr(err)
 	}
 
 	// Provision files
-	if err := copyFiles(ssh, config, createFiles); err != nil {
-		return diag.FromErr(fmt.Errorf("copying files to remote: %w", err))
+	if err := copyFiles(ssh, config, files); err != nil {
+		return nil, diag.FromErr(fmt.Errorf("copying files to remote: %w", err))
 	}
 
 	// Ensure ready-ness
 	if err := ensureContainerHostReady(ssh, config); err != nil {
-		return diag.FromErr(fmt.Errorf("container host ready-ness check failed: %w", err))
+		return nil, diag.FromErr(fmt.Errorf("container host ready-ness check failed: %w", err))
 	}
 
 	// Run commands
-	var stdout, stderr string
-	var done bool
-	for i := 0; i < len(commands); i++ {
-		stdout, stderr, done, err = ssh.Run(commands[i], 5*time.Minute)
-		_, _ = config.Debug("command: %s\ndone: %t\nstdout:\n%s\nstderr:\n%s\n", commands[i], done, stdout, stderr)
-		if err != nil {
-			_, _ = config.Debug("error: %v\n", err)
-			return append(diags, diag.FromErr(fmt.Errorf("command [%s]: %w", commands[i], err))...)
-		}
-	}
-	_ = d.Set("result", stdout)
-	d.SetId(fmt.Sprintf("%d", rand.Int()))
-	return diags
+	return runCommands(ssh, config, commands, commandOptions{
+		Timeouts:        timeouts,
+		ContinueOnError: d.Get("continue_on_error").(bool),
+	})
 }
 
-func resourceContainerHostExecRead(_ *schema.ResourceData, _ interface{}) error {
-	return nil
-}
+// containerHostSSHConfig validates the SSH arguments and returns the connection details of
+// host. The bastion defaults to the one of the Cartel region
+func containerHostSSHConfig(d *schema.ResourceData, config *Config) (*sshConfig, diag.Diagnostics) {
+	client, err := config.CartelClient()
+	if err != nil {
+		return nil, diag.FromErr(err)
+	}
+	bastionHost := d.Get("bastion_host").(string)
+	if bastionHost == "" {
+		bastionHost = client.BastionHost()
+	}
+	user := d.Get("user").(string)
+	privateKey := d.Get("private_key").(string)
+	host := d.Get("host").(string)
+	agent := d.Get("agent").(bool)
 
-func resourceContainerHostExecDelete(d *schema.ResourceData, _ interface{}) error {
-	d.SetId("")
-	return nil
+	if user == "" {
+		return nil, diag.FromErr(fmt.Errorf("user must be set when '%s' is specified", commandsField))
+	}
+	if privateKey == "" && !agent {
+		return nil, diag.FromErr(fmt.Errorf("no SSH 'private_key' was set and 'agent' is 'false', authentication will fail after provisioning step"))
+	}
+	// Collect SSH details
+	privateIP := host
+	if privateKey != "" && agent {
+		return nil, diag.FromErr(fmt.Errorf("'agent' is enabled so not expecting a private key to be set"))
+	}
+	return expandSSHConfig(d, privateIP, bastionHost), nil
 }
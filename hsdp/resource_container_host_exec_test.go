@@ -0,0 +1,144 @@
+package hsdp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerHostExecDiff(t *testing.T) {
+	r := resourceContainerHostExec()
+	state := &terraform.InstanceState{
+		ID: "1",
+		Attributes: map[string]string{
+			"id":                 "1",
+			"host":               "10.0.0.1",
+			"user":               "core",
+			"commands.#":         "1",
+			"commands.0":         "echo one",
+			"command_timeout":    "300",
+			"continue_on_error":  "false",
+			"agent":              "false",
+			"file_checksums.%":   "0",
+			"command_results.#":  "0",
+			"update_commands.#":  "0",
+			"destroy_commands.#": "0",
+		},
+	}
+	raw := map[string]interface{}{
+		"host":     "10.0.0.1",
+		"user":     "core",
+		"commands": []interface{}{"echo two"},
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	if assert.Nil(t, err) && assert.NotNil(t, diff) {
+		assert.True(t, diff.RequiresNew())
+	}
+
+	raw["update_commands"] = []interface{}{"echo updated"}
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	if assert.Nil(t, err) && assert.NotNil(t, diff) {
+		assert.False(t, diff.RequiresNew())
+	}
+}
+
+func TestContainerHostExecUpdateKeepsChecksumsOnFailure(t *testing.T) {
+	r := resourceContainerHostExec()
+	state := &terraform.InstanceState{
+		ID: "1",
+		Attributes: map[string]string{
+			"id":                           "1",
+			"host":                         "127.0.0.1",
+			"bastion_host":                 "127.0.0.1",
+			"user":                         "core",
+			"agent":                        "false",
+			"commands.#":                   "1",
+			"commands.0":                   "echo one",
+			"update_commands.#":            "1",
+			"update_commands.0":            "echo updated",
+			"command_timeout":              "300",
+			"continue_on_error":            "false",
+			"file_checksums.%":             "1",
+			"file_checksums./tmp/app.conf": "old-checksum",
+		},
+	}
+	raw := map[string]interface{}{
+		"host":            "127.0.0.1",
+		"bastion_host":    "127.0.0.1",
+		"user":            "core",
+		"commands":        []interface{}{"echo one"},
+		"update_commands": []interface{}{"echo updated"},
+		"file": []interface{}{
+			map[string]interface{}{"destination": "/tmp/app.conf", "content": "new"},
+		},
+	}
+	meta := &Config{}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) || !assert.NotNil(t, diff) {
+		return
+	}
+	assert.False(t, diff.RequiresNew())
+
+	// Without SSH credentials nothing is uploaded, so the old checksums are kept for the next apply
+	newState, diags := r.Apply(context.Background(), state, diff, meta)
+	assert.True(t, diags.HasError())
+	if assert.NotNil(t, newState) {
+		assert.Equal(t, "old-checksum", newState.Attributes["file_checksums./tmp/app.conf"])
+	}
+}
+
+func TestContainerHostExecDeleteSkipsGoneHost(t *testing.T) {
+	instances := `[{"instance_id":"i-1","name_tag":"other","private_address":"10.0.0.2","state":"running"}]`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(instances))
+	}))
+	defer server.Close()
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	config.setupCartelClient()
+
+	r := resourceContainerHostExec()
+	state := &terraform.InstanceState{
+		ID: "1",
+		Attributes: map[string]string{
+			"id":                 "1",
+			"host":               "127.0.0.1",
+			"bastion_host":       "127.0.0.1",
+			"host_key":           "SHA256:host",
+			"bastion_host_key":   "SHA256:bastion",
+			"user":               "core",
+			"agent":              "true",
+			"command_timeout":    "300",
+			"destroy_commands.#": "1",
+			"destroy_commands.0": "cleanup",
+		},
+	}
+	destroy := &terraform.InstanceDiff{Destroy: true}
+
+	// The instance is no longer known to Cartel
+	newState, diags := r.Apply(context.Background(), state, destroy, config)
+	assert.False(t, diags.HasError(), "%v", diags)
+	if assert.Len(t, diags, 1) {
+		assert.Contains(t, diags[0].Detail, "no longer exists")
+	}
+	assert.Nil(t, newState)
+
+	// The instance exists but does not accept connections
+	instances = `[{"instance_id":"i-1","name_tag":"exec","private_address":"127.0.0.1","state":"running"}]`
+	newState, diags = r.Apply(context.Background(), state, destroy, config)
+	assert.False(t, diags.HasError(), "%v", diags)
+	if assert.Len(t, diags, 1) {
+		assert.Equal(t, "destroy_commands skipped", diags[0].Summary)
+	}
+	assert.Nil(t, newState)
+}
@@ -0,0 +1,419 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	siderite "github.com/philips-labs/siderite/models"
+)
+
+func resourceContainerHostPowerSchedule() *schema.Resource {
+	return &schema.Resource{
+		Importer: &schema.ResourceImporter{
+			StateContext: importContainerHostPowerSchedule,
+		},
+		CreateContext: resourceContainerHostPowerScheduleCreate,
+		ReadContext:   resourceContainerHostPowerScheduleRead,
+		UpdateContext: resourceContainerHostPowerScheduleUpdate,
+		DeleteContext: resourceContainerHostPowerScheduleDelete,
+		CustomizeDiff: customizePowerScheduleBackendDiff,
+
+		Schema: map[string]*schema.Schema{
+			"name": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"host_names": {
+				Type:     schema.TypeSet,
+				Required: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"stop_schedule": {
+				Type:             schema.TypeString,
+				Required:         true,
+				ValidateDiagFunc: validateCron,
+			},
+			"start_schedule": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ValidateDiagFunc: validateCron,
+			},
+			"cartel_credentials": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"host": {
+							Type:     schema.TypeString,
+							Optional: true,
+						},
+						"token": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+						"secret": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+			"docker_image": {
+				Type:     schema.TypeString,
+				Required: true,
+			},
+			"docker_credentials": {
+				Type:      schema.TypeMap,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"command": {
+				Type:     schema.TypeList,
+				Optional: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"timeout": {
+				Type:     schema.TypeInt,
+				Optional: true,
+				Default:  300,
+			},
+			"backend": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"credentials": {
+							Type:      schema.TypeMap,
+							Optional:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+			"stop_schedule_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"start_schedule_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// customizePowerScheduleBackendDiff replaces the schedule when the backend changes. An imported
+// schedule has no backend in its state yet, adopting the configured backend is done in place
+func customizePowerScheduleBackendDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Id() == "" || !d.HasChange("backend") {
+		return nil
+	}
+	if o, _ := d.GetChange("backend"); len(o.([]interface{})) == 0 {
+		return nil
+	}
+	return d.ForceNew("backend")
+}
+
+// importContainerHostPowerSchedule takes an ID in the format {name}/{codeID}-{signature}. The backend
+// credentials are not known during import, the schedules are read on the next plan and recreated
+// with the configured values on the next apply
+func importContainerHostPowerSchedule(_ context.Context, d *schema.ResourceData, _ interface{}) ([]*schema.ResourceData, error) {
+	parts := strings.SplitN(d.Id(), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || len(strings.Split(parts[1], "-")) != 2 {
+		return nil, fmt.Errorf("expected import ID in the format name/codeID-signature, got '%s'", d.Id())
+	}
+	_ = d.Set("name", parts[0])
+	d.SetId(parts[1])
+	return []*schema.ResourceData{d}, nil
+}
+
+// powerScheduleCartelEnvironment returns the Cartel host and the scoped Cartel credentials
+// of the schedule. The credentials of the provider are never handed to the image
+func powerScheduleCartelEnvironment(config *Config, d *schema.ResourceData) map[string]string {
+	env := map[string]string{
+		"CARTEL_HOST": config.CartelHost,
+	}
+	if list, ok := d.Get("cartel_credentials").([]interface{}); ok && len(list) > 0 {
+		if m, ok := list[0].(map[string]interface{}); ok {
+			if host, _ := m["host"].(string); host != "" {
+				env["CARTEL_HOST"] = host
+			}
+			env["CARTEL_TOKEN"], _ = m["token"].(string)
+			env["CARTEL_SECRET"], _ = m["secret"].(string)
+		}
+	}
+	return env
+}
+
+// powerScheduleCommand returns the command of the image, which defaults to /app/server
+func powerScheduleCommand(d *schema.ResourceData) []string {
+	command := []string{"/app/server"}
+	if list, ok := d.Get("command").([]interface{}); ok && len(list) > 0 {
+		command = []string{}
+		for i := 0; i < len(list); i++ {
+			command = append(command, list[i].(string))
+		}
+	}
+	return command
+}
+
+// powerSchedulePayload encrypts the instructions for a single start or stop run. The image calls
+// Cartel suspend or start for every name tag, the contract is documented with the resource
+func powerSchedulePayload(config *Config, backend FunctionBackend, d *schema.ResourceData, action string) (string, error) {
+	hostNames := expandStringList(d.Get("host_names").(*schema.Set).List())
+	sort.Strings(hostNames)
+
+	env := powerScheduleCartelEnvironment(config, d)
+	env["CARTEL_NAME_TAGS"] = strings.Join(hostNames, ",")
+	env["CARTEL_ACTION"] = action
+
+	gateway := backend.Gateway()
+	payload := siderite.Payload{
+		Version:  "1",
+		Type:     "cron",
+		Token:    gateway.Token,
+		Upstream: gateway.Upstream,
+		Auth:     gateway.AuthType,
+		Cmd:      powerScheduleCommand(d),
+		Env:      env,
+		Mode:     "sync",
+	}
+	payloadJSON, err := json.Marshal(&payload)
+	if err != nil {
+		return "", fmt.Errorf("powerSchedulePayload: %w", err)
+	}
+	encrypted, err := backend.EncryptPayload(payloadJSON)
+	if err != nil {
+		return "", fmt.Errorf("powerSchedulePayload.%s: %w", action, err)
+	}
+	return encrypted, nil
+}
+
+// createPowerSchedules creates the stop and (optional) start CRON schedules
+func createPowerSchedules(backend FunctionBackend, d *schema.ResourceData, config *Config, codeName string) (string, string, error) {
+	schedules := map[string]string{
+		"stop":  d.Get("stop_schedule").(string),
+		"start": d.Get("start_schedule").(string),
+	}
+	ids := map[string]string{}
+	startAt := time.Now().Add(aLongTime * time.Second)
+	for _, action := range []string{"stop", "start"} {
+		schedule := schedules[action]
+		if schedule == "" {
+			continue
+		}
+		encryptedPayload, err := powerSchedulePayload(config, backend, d, action)
+		if err != nil {
+			return "", "", err
+		}
+		jsonPayload, _ := json.Marshal(siderite.CronPayload{
+			Schedule:         schedule,
+			EncryptedPayload: encryptedPayload,
+			Timeout:          d.Get("timeout").(int),
+		})
+		created, err := backend.CreateSchedule(FunctionSchedule{
+			CodeName: codeName,
+			Payload:  string(jsonPayload),
+			Cluster:  backend.ClusterID(),
+			StartAt:  &startAt,
+			RunEvery: aLongTime,
+		})
+		if err != nil {
+			return "", "", fmt.Errorf("create %s schedule: %w", action, err)
+		}
+		ids[action] = created.ID
+	}
+	return ids["stop"], ids["start"], nil
+}
+
+func resourceContainerHostPowerScheduleCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+	if powerScheduleCartelEnvironment(config, d)["CARTEL_HOST"] == "" {
+		return diag.FromErr(fmt.Errorf("power schedules require a Cartel host, set it in cartel_credentials or the provider"))
+	}
+	backend, err := newFunctionBackend(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if _, ok := d.GetOk("docker_credentials"); ok {
+		if _, err := dockerLogin(backend, d); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	signature := strings.Replace(uuid.New().String(), "-", "", -1)
+	codeName := fmt.Sprintf("%s-%s", d.Get("name").(string), signature)
+	createdCode, err := backend.CreateCode(codeName, d.Get("docker_image").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	stopID, startID, err := createPowerSchedules(backend, d, config, codeName)
+	if err != nil {
+		_ = backend.DeleteCode(createdCode.ID)
+		return diag.FromErr(err)
+	}
+	d.SetId(fmt.Sprintf("%s-%s", createdCode.ID, signature))
+	_ = d.Set("stop_schedule_id", stopID)
+	_ = d.Set("start_schedule_id", startID)
+	return resourceContainerHostPowerScheduleRead(ctx, d, m)
+}
+
+func resourceContainerHostPowerScheduleRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	if _, err := expandBackendCredentials(d); err != nil {
+		// Freshly imported, the backend is only known once the configuration is applied
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("power schedule %s has no backend in its state", d.Id()),
+			Detail:   "the schedules are recreated with the configured values on the next apply",
+		})
+	}
+	backend, err := newFunctionBackend(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	// ID Format: {codeID}-{signature}
+	ids := strings.Split(d.Id(), "-")
+	if len(ids) < 2 {
+		d.SetId("") // Malformed
+		return diags
+	}
+	codeID := ids[0]
+	code, err := backend.GetCode(codeID)
+	if err != nil || code == nil {
+		d.SetId("")
+		return diags
+	}
+	_ = d.Set("docker_image", code.Image)
+
+	schedules, err := backend.GetSchedules(code.Name)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	found := map[string]decodedSchedule{}
+	for _, s := range schedules {
+		found[s.ID] = decodeSchedule(backend, s)
+	}
+	// Clear schedules which were removed outside of Terraform so they show up in the plan
+	stop, ok := found[d.Get("stop_schedule_id").(string)]
+	if !ok {
+		_ = d.Set("stop_schedule", "")
+		_ = d.Set("stop_schedule_id", "")
+	}
+	start, hasStart := found[d.Get("start_schedule_id").(string)]
+	if id := d.Get("start_schedule_id").(string); id != "" && !hasStart {
+		_ = d.Set("start_schedule", "")
+		_ = d.Set("start_schedule_id", "")
+	}
+	if !ok {
+		return diags
+	}
+	// Schedules changed outside of Terraform take the values found on the backend, so the
+	// plan recreates them with the configured values
+	_ = d.Set("stop_schedule", stop.Schedule)
+	_ = d.Set("timeout", stop.Timeout)
+	if hasStart {
+		_ = d.Set("start_schedule", start.Schedule)
+	}
+	if stop.Payload == nil {
+		_, _ = config.Debug("payloads of power schedule '%s' can not be decrypted, skipping host_names and command: %v\n", d.Get("name"), stop.PayloadErr)
+		if errors.Is(stop.PayloadErr, errPayloadNotDecryptable) {
+			return diags
+		}
+		return append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  fmt.Sprintf("host_names and command of power schedule %s were not checked for drift", d.Get("name")),
+			Detail:   fmt.Sprintf("the schedule payloads can not be decrypted with the cluster_info_0_private_key backend credential: %v", stop.PayloadErr),
+		})
+	}
+	if names := stop.Payload.Env["CARTEL_NAME_TAGS"]; names != "" {
+		_ = d.Set("host_names", strings.Split(names, ","))
+	}
+	if !reflect.DeepEqual(stop.Payload.Cmd, powerScheduleCommand(d)) {
+		_ = d.Set("command", stop.Payload.Cmd)
+	}
+	return diags
+}
+
+func resourceContainerHostPowerScheduleUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	backend, err := newFunctionBackend(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	ids := strings.Split(d.Id(), "-")
+	codeID := ids[0]
+	codeName := fmt.Sprintf("%s-%s", d.Get("name").(string), ids[1])
+
+	if d.HasChange("docker_credentials") {
+		if _, ok := d.GetOk("docker_credentials"); ok {
+			if _, err := dockerLogin(backend, d); err != nil {
+				return diag.FromErr(err)
+			}
+		}
+	}
+	if d.HasChange("docker_image") {
+		code, err := backend.GetCode(codeID)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		if code == nil {
+			return diag.FromErr(fmt.Errorf("code %s not found", codeID))
+		}
+		code.Image = d.Get("docker_image").(string)
+		if err := backend.UpdateCode(*code); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	if d.HasChanges("host_names", "stop_schedule", "start_schedule", "command", "timeout", "cartel_credentials", "backend") {
+		schedules, err := backend.GetSchedules(codeName)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		stopID, startID, err := createPowerSchedules(backend, d, config, codeName)
+		if err != nil {
+			return diag.FromErr(err)
+		}
+		// Clear old ones
+		for _, s := range schedules {
+			_ = backend.CancelSchedule(s.ID)
+		}
+		_ = d.Set("stop_schedule_id", stopID)
+		_ = d.Set("start_schedule_id", startID)
+	}
+	return resourceContainerHostPowerScheduleRead(ctx, d, m)
+}
+
+func resourceContainerHostPowerScheduleDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	backend, err := newFunctionBackend(d, m)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	codeID := strings.Split(d.Id(), "-")[0]
+	// Deleting a code cascade deletes schedules as well
+	if err := backend.DeleteCode(codeID); err != nil {
+		return diag.FromErr(err)
+	}
+	d.SetId("")
+	return diags
+}
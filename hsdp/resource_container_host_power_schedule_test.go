@@ -0,0 +1,131 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	siderite "github.com/philips-labs/siderite/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerHostPowerSchedule(t *testing.T) {
+	r := resourceContainerHostPowerSchedule()
+	meta := &Config{CartelHost: "cartel.example.com", CartelToken: "provider-token", CartelSecret: "provider-secret"}
+	stateDir := t.TempDir()
+	backendBlock := []interface{}{
+		map[string]interface{}{
+			"credentials": map[string]interface{}{
+				"type":      "fake",
+				"state_dir": stateDir,
+			},
+		},
+	}
+	raw := map[string]interface{}{
+		"name":           "office-hours",
+		"host_names":     []interface{}{"dev", "test"},
+		"stop_schedule":  "0 20 * * 1-5",
+		"start_schedule": "0 7 * * 1-5",
+		"docker_image":   "cartel-power:latest",
+		"cartel_credentials": []interface{}{
+			map[string]interface{}{"token": "scoped-token", "secret": "scoped-secret"},
+		},
+		"backend": backendBlock,
+	}
+	diff, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) {
+		return
+	}
+	state, diags := r.Apply(context.Background(), nil, diff, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+
+	b, _ := newFakeFunctionBackend(meta, map[string]string{"state_dir": stateDir})
+	backend := b.(*fakeFunctionBackend)
+	code, _ := backend.GetCode(strings.Split(state.ID, "-")[0])
+	if !assert.NotNil(t, code) {
+		return
+	}
+	schedules, _ := backend.GetSchedules(code.Name)
+	if !assert.Len(t, schedules, 2) {
+		return
+	}
+	// Only the scoped credentials are handed to the image
+	decoded := decodeSchedule(backend, schedules[0])
+	if assert.NotNil(t, decoded.Payload) {
+		assert.Equal(t, "scoped-token", decoded.Payload.Env["CARTEL_TOKEN"])
+		assert.Equal(t, "scoped-secret", decoded.Payload.Env["CARTEL_SECRET"])
+		assert.Equal(t, "cartel.example.com", decoded.Payload.Env["CARTEL_HOST"])
+		assert.Equal(t, "dev,test", decoded.Payload.Env["CARTEL_NAME_TAGS"])
+	}
+
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.Empty(t, diags)
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	assert.Nil(t, err)
+	assert.True(t, diff.Empty(), "unexpected diff: %v", diff)
+
+	// Change the hosts and stop schedule behind the back of Terraform
+	for _, s := range schedules {
+		var cronPayload siderite.CronPayload
+		_ = json.Unmarshal([]byte(s.Payload), &cronPayload)
+		plain, _ := base64.StdEncoding.DecodeString(cronPayload.EncryptedPayload)
+		var payload siderite.Payload
+		_ = json.Unmarshal(plain, &payload)
+		payload.Env["CARTEL_NAME_TAGS"] = "dev"
+		plain, _ = json.Marshal(payload)
+		cronPayload.EncryptedPayload = base64.StdEncoding.EncodeToString(plain)
+		if cronPayload.Schedule == "0 20 * * 1-5" {
+			cronPayload.Schedule = "0 22 * * 1-5"
+		}
+		jsonPayload, _ := json.Marshal(cronPayload)
+		s.Payload = string(jsonPayload)
+		assert.Nil(t, backend.write("schedules", s.ID, s))
+	}
+	state, diags = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "0 22 * * 1-5", state.Attributes["stop_schedule"])
+	assert.Equal(t, "1", state.Attributes["host_names.#"])
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	if !assert.Nil(t, err) || !assert.NotNil(t, diff) {
+		return
+	}
+	assert.False(t, diff.RequiresNew())
+	assert.Equal(t, "0 20 * * 1-5", diff.Attributes["stop_schedule"].New)
+	assert.Equal(t, "2", diff.Attributes["host_names.#"].New)
+
+	state, diags = r.Apply(context.Background(), state, diff, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	state, _ = r.RefreshWithoutUpgrade(context.Background(), state, meta)
+	diff, _ = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), meta)
+	assert.True(t, diff.Empty(), "unexpected diff: %v", diff)
+
+	// Import: the schedules are adopted on the first apply without replacing the code
+	d := r.TestResourceData()
+	d.SetId("office-hours/" + state.ID)
+	imported, err := r.Importer.StateContext(context.Background(), d, meta)
+	if !assert.Nil(t, err) || !assert.Len(t, imported, 1) {
+		return
+	}
+	importedState := imported[0].State()
+	importedState, diags = r.RefreshWithoutUpgrade(context.Background(), importedState, meta)
+	if assert.Len(t, diags, 1) {
+		assert.Contains(t, diags[0].Summary, "no backend in its state")
+	}
+	diff, err = r.Diff(context.Background(), importedState, terraform.NewResourceConfigRaw(raw), meta)
+	if assert.Nil(t, err) && assert.NotNil(t, diff) {
+		assert.False(t, diff.RequiresNew())
+	}
+	importedState, diags = r.Apply(context.Background(), importedState, diff, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, state.ID, importedState.ID)
+	schedules, _ = backend.GetSchedules(code.Name)
+	assert.Len(t, schedules, 2)
+
+	_, err = r.Importer.StateContext(context.Background(), r.TestResourceData(), meta)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,192 @@
+package hsdp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/philips-software/go-hsdp-api/cartel"
+)
+
+// replacementFields can only be changed by replacing the host
+var replacementFields = []string{"instance_role", "image", "volume_type", "encrypt_volumes", "subnet_type", "subnet"}
+
+// replacementComputedFields change when the host is replaced in place using blue_green
+var replacementComputedFields = []string{"host_name", "private_ip", "public_ip", "zone", "launch_time", "block_devices", hostKeyField}
+
+// customizeContainerHostReplaceDiff replaces the host when a replacement field changes. With blue_green
+// the replacement happens during update instead, so the new addresses are planned as unknown and
+// resources which depend on them are updated in the same apply
+func customizeContainerHostReplaceDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	var changed []string
+	for _, field := range replacementFields {
+		if d.HasChange(field) {
+			changed = append(changed, field)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if !d.Get("blue_green").(bool) {
+		for _, field := range changed {
+			if err := d.ForceNew(field); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, field := range replacementComputedFields {
+		if err := d.SetNewComputed(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// generateHostName returns a unique Cartel name for the replacement of the named host
+func generateHostName(name string) (string, error) {
+	suffix := make([]byte, 3)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s-%s", name, hex.EncodeToString(suffix)), nil
+}
+
+// replaceContainerHost creates a new host next to the current one, provisions it and only then moves
+// the tags over and destroys the current host. The state keeps pointing to the current host until
+// the new one is healthy
+func replaceContainerHost(ctx context.Context, d *schema.ResourceData, config *Config, client *cartel.Client) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// Keep the previous state when anything fails before the swap
+	d.Partial(true)
+
+	oldName := containerHostName(d)
+	newName, err := generateHostName(d.Get("name").(string))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	timeout := d.Timeout(schema.TimeoutUpdate)
+	keepFailedInstances := d.Get("keep_failed_instances").(bool)
+	bastionHost := d.Get("bastion_host").(string)
+	if bastionHost == "" {
+		bastionHost = client.BastionHost()
+	}
+
+	createFiles, diags := collectFilesToCreate(d)
+	if len(diags) > 0 {
+		return diags
+	}
+	commands, diags := collectList(commandsField, d)
+	if len(diags) > 0 {
+		return diags
+	}
+
+	abandon := func(err error) diag.Diagnostics {
+		if !keepFailedInstances {
+			_, _, _ = client.Destroy(newName)
+		} else {
+			diags = append(diags, diag.FromErr(fmt.Errorf("'keep_failed_instances' is enabled so not removing '%s', remember to destroy it manually", newName))...)
+		}
+		return append(diags, diag.FromErr(fmt.Errorf("replacing '%s' with '%s': %w", oldName, newName, err))...)
+	}
+
+	// User groups are granted right away so provisioning can log in, tags follow after the checks
+	_, _ = config.Debug("creating replacement '%s' for '%s'\n", newName, oldName)
+	if _, _, err := client.Create(newName, containerHostOptions(d, map[string]string{})...); err != nil {
+		return abandon(err)
+	}
+	if err := waitForDeployment(ctx, client, newName, timeout); err != nil {
+		return abandon(err)
+	}
+	details, _, err := client.GetDetails(newName)
+	if err != nil {
+		return abandon(err)
+	}
+
+	// The new host has its own host key
+	_ = d.Set(hostKeyField, "")
+	ssh := expandSSHConfig(d, details.PrivateAddress, bastionHost)
+	if _, hasReadinessCheck := expandReadinessCheck(d); len(commands) > 0 || len(createFiles) > 0 || hasReadinessCheck {
+		if err := pinHostKeys(ssh, d); err != nil {
+			return abandon(err)
+		}
+		if err := waitForHostReady(ssh, config, d); err != nil {
+			return abandon(fmt.Errorf("not deemed healthy: %w", err))
+		}
+	}
+	if err := copyFiles(ssh, config, createFiles); err != nil {
+		return abandon(err)
+	}
+	results, cmdDiags := runCommands(ssh, config, commands, commandOptions{})
+	if cmdDiags.HasError() {
+		return abandon(fmt.Errorf("provisioning failed: %v", cmdDiags[len(cmdDiags)-1].Summary))
+	}
+	if _, _, err := client.AddTags([]string{newName}, expandTags(d)); err != nil {
+		return abandon(fmt.Errorf("adding tags: %w", err))
+	}
+
+	// The new host is in service, from here on it is tracked in the state
+	d.Partial(false)
+	d.SetId(details.InstanceID)
+	_ = d.Set("host_name", newName)
+	_ = d.Set("result", lastStdout(results))
+	if checksums, err := fileChecksums(createFiles); err == nil {
+		_ = d.Set(fileChecksumsField, checksums)
+	}
+	d.SetConnInfo(map[string]string{
+		"type": "ssh",
+		"host": details.PrivateAddress,
+	})
+
+	diags = append(diags, retireContainerHost(d, client, oldName)...)
+
+	if d.Get("power_state").(string) == "stopped" {
+		if err := setPowerState(ctx, client, newName, "stopped", timeout); err != nil {
+			return append(diags, diag.FromErr(fmt.Errorf("stopping '%s': %w", newName, err))...)
+		}
+	}
+	return diags
+}
+
+// retireContainerHost removes the tags and user groups of the replaced host and destroys it. Failures
+// are reported as warnings as the replacement is already in service
+func retireContainerHost(d *schema.ResourceData, client *cartel.Client, oldName string) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	warn := func(summary string, err error) {
+		diags = append(diags, diag.Diagnostic{
+			Severity: diag.Warning,
+			Summary:  summary,
+			Detail:   fmt.Sprintf("'%s': %v, remember to destroy it manually", oldName, err),
+		})
+	}
+	oldTags, _ := d.GetChange("tags")
+	if removal := generateTagChange(oldTags, map[string]interface{}{}); len(removal) > 0 {
+		if _, _, err := client.AddTags([]string{oldName}, removal); err != nil {
+			warn("failed to remove tags of replaced host", err)
+		}
+	}
+	oldGroups, _ := d.GetChange("user_groups")
+	if groups := expandStringList(oldGroups.(*schema.Set).List()); len(groups) > 0 {
+		if _, _, err := client.RemoveUserGroups([]string{oldName}, groups); err != nil {
+			warn("failed to remove user groups of replaced host", err)
+		}
+	}
+	if wasProtected, _ := d.GetChange("protect"); wasProtected.(bool) {
+		if _, _, err := client.SetProtection(oldName, false); err != nil {
+			warn("failed to unprotect replaced host", err)
+			return diags
+		}
+	}
+	if _, _, err := client.Destroy(oldName); err != nil {
+		warn("failed to destroy replaced host", err)
+	}
+	return diags
+}
@@ -0,0 +1,138 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainerHostReplaceDiff(t *testing.T) {
+	r := resourceContainerHost()
+	state := &terraform.InstanceState{
+		ID: "i-1",
+		Attributes: map[string]string{
+			"id":              "i-1",
+			"name":            "web",
+			"host_name":       "web",
+			"image":           "ami-1",
+			"instance_role":   "container-host",
+			"instance_type":   "m5.large",
+			"encrypt_volumes": "true",
+			"private_ip":      "10.0.0.1",
+			"power_state":     "running",
+			"tags.%":          "1",
+			"tags.service":    "web",
+		},
+	}
+	raw := map[string]interface{}{
+		"name":  "web",
+		"image": "ami-2",
+		"tags":  map[string]interface{}{"service": "web"},
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	if assert.Nil(t, err) && assert.NotNil(t, diff) {
+		assert.True(t, diff.RequiresNew())
+	}
+
+	raw["blue_green"] = true
+	diff, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	if assert.Nil(t, err) && assert.NotNil(t, diff) {
+		assert.False(t, diff.RequiresNew())
+		if assert.NotNil(t, diff.Attributes["private_ip"]) {
+			assert.True(t, diff.Attributes["private_ip"].NewComputed)
+		}
+	}
+}
+
+func TestGenerateHostName(t *testing.T) {
+	first, err := generateHostName("web")
+	assert.Nil(t, err)
+	second, _ := generateHostName("web")
+	assert.True(t, strings.HasPrefix(first, "web-"))
+	assert.Len(t, first, len("web-")+6)
+	assert.NotEqual(t, first, second)
+}
+
+func TestReplaceReplacedContainerHost(t *testing.T) {
+	var lookups, destroyed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			NameTag []string `json:"name-tag"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		w.Header().Set("Content-Type", "application/json")
+		switch strings.TrimPrefix(r.URL.Path, "/") {
+		case "v3/api/instance_details":
+			lookups = append(lookups, body.NameTag...)
+			_, _ = w.Write([]byte(`[{"web-abc123":{"instance_id":"i-2","role":"container-host","state":"running"}}]`))
+		case "v3/api/create":
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"code":500,"description":"no capacity"}`))
+		case "v3/api/destroy":
+			destroyed = append(destroyed, body.NameTag...)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	config.setupCartelClient()
+
+	// The host was replaced before, so its Cartel name is no longer 'name'
+	r := resourceContainerHost()
+	state := &terraform.InstanceState{
+		ID: "i-2",
+		Attributes: map[string]string{
+			"id":              "i-2",
+			"name":            "web",
+			"host_name":       "web-abc123",
+			"blue_green":      "true",
+			"image":           "ami-1",
+			"instance_role":   "container-host",
+			"instance_type":   "m5.large",
+			"encrypt_volumes": "true",
+			"power_state":     "running",
+		},
+	}
+	raw := map[string]interface{}{
+		"name":       "web",
+		"image":      "ami-2",
+		"blue_green": true,
+	}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), config)
+	if !assert.Nil(t, err) || !assert.NotNil(t, diff) {
+		return
+	}
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "", d.Get("host_name"))
+	assert.Equal(t, "web-abc123", containerHostName(d))
+
+	diags := resourceContainerHostUpdate(context.Background(), d, config)
+	if assert.True(t, diags.HasError()) {
+		assert.Contains(t, diags[len(diags)-1].Summary, "replacing 'web-abc123' with 'web-")
+	}
+	assert.Equal(t, []string{"web-abc123"}, lookups)
+	if assert.Len(t, destroyed, 1) {
+		assert.NotEqual(t, "web-abc123", destroyed[0])
+	}
+
+	// Once replaced the new host is the one to address
+	_ = d.Set("host_name", "web-def456")
+	assert.Equal(t, "web-def456", containerHostName(d))
+}
@@ -0,0 +1,183 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/resource"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/philips-software/go-hsdp-api/cartel"
+)
+
+// InstancePowerStateRefreshFunc reports the run state (running, stopped, ...) of an instance
+func InstancePowerStateRefreshFunc(client *cartel.Client, nameTag string, failStates []string) resource.StateRefreshFunc {
+	return func() (interface{}, string, error) {
+		details, resp, err := client.GetDetails(nameTag)
+		if err != nil {
+			log.Printf("Error on InstancePowerStateRefresh: %s", err)
+			return resp, "", err
+		}
+		for _, failState := range failStates {
+			if details.State == failState {
+				return details, details.State, fmt.Errorf("failed to reach target state, reason: %s",
+					details.State)
+			}
+		}
+		return details, details.State, nil
+	}
+}
+
+// resizeFields are changed in place with resize_in_place, otherwise they replace the host
+var resizeFields = []string{"instance_type", "volumes", "volume_size", "iops"}
+
+// customizeContainerHostDiff replaces the host when it is resized, unless resize_in_place is
+// set. Shrinking storage always replaces the host, Cartel can only grow volumes
+func customizeContainerHostDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if d.Id() == "" {
+		return nil
+	}
+	inPlace := d.Get("resize_in_place").(bool)
+	for _, field := range resizeFields {
+		if !d.HasChange(field) {
+			continue
+		}
+		forceNew := !inPlace
+		if field == "volumes" || field == "volume_size" {
+			o, n := d.GetChange(field)
+			forceNew = forceNew || n.(int) < o.(int)
+		}
+		if forceNew {
+			if err := d.ForceNew(field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitForPowerState waits until the instance reaches the given run state
+func waitForPowerState(ctx context.Context, client *cartel.Client, tagName, target string, pending []string, timeout time.Duration) error {
+	stateConf := &resource.StateChangeConf{
+		Pending:    pending,
+		Target:     []string{target},
+		Refresh:    InstancePowerStateRefreshFunc(client, tagName, []string{"terminated", "shutting-down"}),
+		Timeout:    timeout,
+		Delay:      5 * time.Second,
+		MinTimeout: 3 * time.Second,
+	}
+	_, err := stateConf.WaitForStateContext(ctx)
+	return err
+}
+
+// waitForDeployment waits until Cartel reports a succeeded deployment
+func waitForDeployment(ctx context.Context, client *cartel.Client, tagName string, timeout time.Duration) error {
+	stateConf := &resource.StateChangeConf{
+		Pending:    []string{"provisioning", "indeterminate"},
+		Target:     []string{"succeeded"},
+		Refresh:    InstanceStateRefreshFunc(client, tagName, []string{"failed", "terminated", "shutting-down"}),
+		Timeout:    timeout,
+		Delay:      10 * time.Second,
+		MinTimeout: 3 * time.Second,
+	}
+	_, err := stateConf.WaitForStateContext(ctx)
+	return err
+}
+
+// setPowerState starts or stops the instance and waits until it reaches the desired state
+func setPowerState(ctx context.Context, client *cartel.Client, tagName, desired string, timeout time.Duration) error {
+	details, _, err := client.GetDetails(tagName)
+	if err != nil {
+		return err
+	}
+	if details.State == desired {
+		return nil
+	}
+	switch desired {
+	case "stopped":
+		if _, _, err := client.Stop(tagName); err != nil {
+			return fmt.Errorf("stopping instance: %w", err)
+		}
+		if err := waitForPowerState(ctx, client, tagName, "stopped", []string{"running", "stopping", "pending"}, timeout); err != nil {
+			return fmt.Errorf("waiting for instance to stop: %w", err)
+		}
+		return nil
+	case "running":
+		if _, _, err := client.Start(tagName); err != nil {
+			return fmt.Errorf("starting instance: %w", err)
+		}
+		if err := waitForPowerState(ctx, client, tagName, "running", []string{"stopped", "stopping", "pending"}, timeout); err != nil {
+			return fmt.Errorf("waiting for instance to start: %w", err)
+		}
+		return waitForDeployment(ctx, client, tagName, timeout)
+	}
+	return fmt.Errorf("unsupported power state '%s'", desired)
+}
+
+// changeInstanceType stops the instance, changes its type and starts it again. When the change
+// fails the instance is started again with its old type
+func changeInstanceType(ctx context.Context, config *Config, client *cartel.Client, tagName, instanceType string, timeout time.Duration) error {
+	_, _ = config.Debug("stopping %s to change instance type to %s\n", tagName, instanceType)
+	if err := setPowerState(ctx, client, tagName, "stopped", timeout); err != nil {
+		return err
+	}
+	_, _, err := cartelRequest(config, cartelChangeInstanceTypePath, tagName, map[string]interface{}{
+		"instance_type": instanceType,
+	})
+	if err != nil {
+		if startErr := setPowerState(ctx, client, tagName, "running", timeout); startErr != nil {
+			return fmt.Errorf("changing instance type: %w (starting the instance again failed: %v)", err, startErr)
+		}
+		return fmt.Errorf("changing instance type: %w", err)
+	}
+	return setPowerState(ctx, client, tagName, "running", timeout)
+}
+
+// growVolumes grows the data volumes of an instance without replacing it
+func growVolumes(ctx context.Context, config *Config, client *cartel.Client, tagName string, d *schema.ResourceData, timeout time.Duration) error {
+	body := map[string]interface{}{
+		"num_vols": d.Get("volumes").(int),
+		"vol_size": d.Get("volume_size").(int),
+	}
+	if iops := d.Get("iops").(int); iops > 0 {
+		body["iops"] = iops
+	}
+	_, _ = config.Debug("modifying volumes of %s: %v\n", tagName, body)
+	if _, _, err := cartelRequest(config, cartelModifyVolumesPath, tagName, body); err != nil {
+		return fmt.Errorf("modifying volumes: %w", err)
+	}
+	return waitForDeployment(ctx, client, tagName, timeout)
+}
+
+// resizeContainerHost applies instance type and volume changes in place
+func resizeContainerHost(ctx context.Context, config *Config, client *cartel.Client, d *schema.ResourceData) error {
+	tagName := containerHostName(d)
+	timeout := d.Timeout(schema.TimeoutUpdate)
+
+	// Check all endpoints up front, so the host is not stopped for a resize Cartel can not do
+	var paths []string
+	if d.HasChange("instance_type") {
+		paths = append(paths, cartelChangeInstanceTypePath)
+	}
+	if d.HasChanges("volumes", "volume_size", "iops") {
+		paths = append(paths, cartelModifyVolumesPath)
+	}
+	for _, path := range paths {
+		if err := cartelEndpointSupported(config, path); err != nil {
+			return err
+		}
+	}
+
+	if d.HasChange("instance_type") {
+		if err := changeInstanceType(ctx, config, client, tagName, d.Get("instance_type").(string), timeout); err != nil {
+			return err
+		}
+	}
+	if d.HasChanges("volumes", "volume_size", "iops") {
+		if err := growVolumes(ctx, config, client, tagName, d, timeout); err != nil {
+			return err
+		}
+	}
+	return nil
+}
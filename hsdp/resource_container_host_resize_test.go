@@ -0,0 +1,109 @@
+package hsdp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCustomizeContainerHostDiff(t *testing.T) {
+	r := resourceContainerHost()
+	state := &terraform.InstanceState{
+		ID: "i-1",
+		Attributes: map[string]string{
+			"id":              "i-1",
+			"name":            "web",
+			"host_name":       "web",
+			"instance_type":   "m5.large",
+			"instance_role":   "container-host",
+			"encrypt_volumes": "true",
+			"volumes":         "2",
+			"volume_size":     "100",
+			"power_state":     "running",
+		},
+	}
+	diff := func(raw map[string]interface{}) *terraform.InstanceDiff {
+		raw["name"] = "web"
+		d, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+		if !assert.Nil(t, err) || !assert.NotNil(t, d) {
+			t.FailNow()
+		}
+		return d
+	}
+
+	// Without resize_in_place resizing replaces the host
+	d := diff(map[string]interface{}{"instance_type": "m5.xlarge", "volumes": 2, "volume_size": 100})
+	assert.True(t, d.RequiresNew())
+	assert.True(t, d.Attributes["instance_type"].RequiresNew)
+	d = diff(map[string]interface{}{"volumes": 3, "volume_size": 100})
+	assert.True(t, d.Attributes["volumes"].RequiresNew)
+
+	// Growing storage and changing the instance type are done in place when opted in
+	d = diff(map[string]interface{}{"resize_in_place": true, "instance_type": "m5.xlarge", "volumes": 3, "volume_size": 200})
+	assert.False(t, d.RequiresNew())
+	assert.Equal(t, "m5.xlarge", d.Attributes["instance_type"].New)
+
+	// Shrinking either the number or the size of the volumes recreates the host
+	d = diff(map[string]interface{}{"resize_in_place": true, "volumes": 1, "volume_size": 100})
+	assert.True(t, d.RequiresNew())
+	assert.True(t, d.Attributes["volumes"].RequiresNew)
+	assert.False(t, d.Attributes["volume_size"] != nil && d.Attributes["volume_size"].RequiresNew)
+
+	d = diff(map[string]interface{}{"resize_in_place": true, "volumes": 2, "volume_size": 50})
+	assert.True(t, d.RequiresNew())
+	assert.True(t, d.Attributes["volume_size"].RequiresNew)
+}
+
+func TestResizeContainerHostChecksEndpointsBeforeStopping(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, strings.TrimPrefix(r.URL.Path, "/"))
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(r.URL.Path, "change_instance_type") {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	config := &Config{
+		CartelHost:   strings.TrimPrefix(server.URL, "http://"),
+		CartelToken:  "token",
+		CartelSecret: "secret",
+		CartelNoTLS:  true,
+	}
+	config.setupCartelClient()
+	client, err := config.CartelClient()
+	if !assert.Nil(t, err) {
+		return
+	}
+
+	r := resourceContainerHost()
+	state := &terraform.InstanceState{ID: "i-1", Attributes: map[string]string{
+		"id":            "i-1",
+		"name":          "web",
+		"instance_type": "m5.large",
+	}}
+	diff, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+		"name":            "web",
+		"resize_in_place": true,
+		"instance_type":   "m5.xlarge",
+	}), config)
+	if !assert.Nil(t, err) {
+		return
+	}
+	d, err := schema.InternalMap(r.Schema).Data(state, diff)
+	if !assert.Nil(t, err) {
+		return
+	}
+	err = resizeContainerHost(context.Background(), config, client, d)
+	assert.True(t, errors.Is(err, ErrCartelEndpointUnsupported))
+	assert.Equal(t, []string{cartelChangeInstanceTypePath}, paths)
+}
@@ -4,17 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
 	"github.com/docker/distribution/reference"
 	"github.com/google/uuid"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/customdiff"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
-	"github.com/philips-labs/ferrite/server"
 	siderite "github.com/philips-labs/siderite/models"
-	"github.com/philips-software/go-hsdp-api/iron"
 	"github.com/robfig/cron/v3"
 )
 
@@ -32,6 +30,7 @@ func resourceFunction() *schema.Resource {
 		ReadContext:   resourceFunctionRead,
 		UpdateContext: resourceFunctionUpdate,
 		DeleteContext: resourceFunctionDelete,
+		CustomizeDiff: customdiff.All(customizeFunctionTriggersDiff, customizeFunctionScheduleDiff),
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -79,6 +78,16 @@ func resourceFunction() *schema.Resource {
 				Optional: true,
 				Default:  1800,
 			},
+			scheduleMissingField: {
+				Type:     schema.TypeBool,
+				Computed: true,
+			},
+			triggerField: triggerSchema(),
+			triggerSchedulesField: {
+				Type:     schema.TypeMap,
+				Computed: true,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
 			"backend": {
 				Type:     schema.TypeList,
 				Required: true,
@@ -118,7 +127,7 @@ func resourceFunction() *schema.Resource {
 
 func resourceFunctionDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
-	ironClient, _, _, err := newIronClient(d, m)
+	backend, err := newFunctionBackend(d, m)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -128,8 +137,8 @@ func resourceFunctionDelete(_ context.Context, d *schema.ResourceData, m interfa
 		return diags
 	}
 	codeID := ids[0]
-	_ = ids[1]                                      // signature
-	_, _, err = ironClient.Codes.DeleteCode(codeID) // Deleting a code cascade deletes schedules as well, jobs done!
+	_ = ids[1]                       // signature
+	err = backend.DeleteCode(codeID) // Deleting a code cascade deletes schedules as well, jobs done!
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -141,7 +150,7 @@ func resourceFunctionDelete(_ context.Context, d *schema.ResourceData, m interfa
 func resourceFunctionUpdate(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 
-	ironClient, ironConfig, modConfig, err := newIronClient(d, m)
+	backend, err := newFunctionBackend(d, m)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -149,7 +158,7 @@ func resourceFunctionUpdate(_ context.Context, d *schema.ResourceData, m interfa
 
 	if d.HasChange("docker_credentials") {
 		if _, ok := d.GetOk("docker_credentials"); ok {
-			if _, err := dockerLogin(ironClient, d); err != nil {
+			if _, err := dockerLogin(backend, d); err != nil {
 				return diag.FromErr(err)
 			}
 		}
@@ -166,81 +175,95 @@ func resourceFunctionUpdate(_ context.Context, d *schema.ResourceData, m interfa
 	name := d.Get("name").(string)
 	codeName := fmt.Sprintf("%s-%s", name, signature)
 	if d.HasChange("docker_image") {
-		code, _, err := ironClient.Codes.GetCode(codeID)
-		if err != nil {
+		code, err := backend.GetCode(codeID)
+		if err != nil || code == nil {
 			d.SetId("")
 			return diags
 		}
 		code.Image = d.Get("docker_image").(string)
-		_, resp, err := ironClient.Codes.CreateOrUpdateCode(*code)
-		if err != nil {
-			return diag.FromErr(fmt.Errorf("CreateOrUpdateCode(%v): %w", code, err))
-		}
-		if resp.StatusCode != http.StatusOK {
-			return diag.FromErr(fmt.Errorf("failed to update code: %d", resp.StatusCode))
+		if err := backend.UpdateCode(*code); err != nil {
+			return diag.FromErr(err)
 		}
 	}
 
 	if d.HasChange("schedule") || d.HasChange("command") ||
 		d.HasChange("run_every") || d.HasChange("environment") ||
-		d.HasChange("start_at") || d.HasChange("image") || d.HasChange("timeout") {
-		schedules, _, err := ironClient.Schedules.GetSchedulesWithCode(codeName)
+		d.HasChange("start_at") || d.HasChange("image") || d.HasChange("timeout") ||
+		d.HasChange(scheduleMissingField) {
+		schedules, err := backend.GetSchedules(codeName)
 		if err != nil {
-			return diag.FromErr(fmt.Errorf("GetSchedulesWithCode(%s): %w", codeName, err))
+			return diag.FromErr(err)
 		}
 		// Create new schedules
-		diags = createSchedules(ironClient, ironConfig, *modConfig, d, codeName, codeID, signature)
+		diags = createSchedules(backend, d, codeName, codeID, signature)
 		if len(diags) > 0 {
 			return diags
 		}
-		// Clear old ones
-		for _, s := range *schedules {
-			_, _, _ = ironClient.Schedules.CancelSchedule(s.ID)
+		// Clear old ones, the trigger schedules are reconciled below
+		triggerIDs := triggerScheduleIDs(d)
+		for _, s := range schedules {
+			if !triggerIDs[s.ID] {
+				_ = backend.CancelSchedule(s.ID)
+			}
 		}
 	}
-	_, _ = config.Debug("ProjectID: %v\nSignature: %v\nCode: %v\n", ironConfig.ProjectID, signature, codeID)
+	if d.HasChanges(triggerField, triggerSchedulesField, "command", "environment", "timeout") {
+		ids, triggerDiags := reconcileTriggers(backend, d, codeName)
+		_ = d.Set(triggerSchedulesField, ids)
+		if len(triggerDiags) > 0 {
+			return append(diags, triggerDiags...)
+		}
+	}
+	_, _ = config.Debug("Cluster: %v\nSignature: %v\nCode: %v\n", backend.ClusterID(), signature, codeID)
 	return diags
 }
 
 func resourceFunctionRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
 	var diags diag.Diagnostics
 	config := m.(*Config)
-	ironClient, ironConfig, _, err := newIronClient(d, m)
+	backend, err := newFunctionBackend(d, m)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("resourceFunctionRead.newIronClient: %w", err))
+		return diag.FromErr(fmt.Errorf("resourceFunctionRead.newFunctionBackend: %w", err))
 	}
 	// ID Format: {codeID}-{signature}
 	ids := strings.Split(d.Id(), "-")
 	codeID := ids[0]
 	signature := ids[1]
 
-	code, _, err := ironClient.Codes.GetCode(codeID)
+	code, err := backend.GetCode(codeID)
 	if err != nil {
 		return diag.FromErr(fmt.Errorf("resourceFunctionRead.GetCode: %w", err))
 	}
-	if code == nil || code.ID != codeID {
+	if code == nil {
 		_, _ = config.Debug("could not find code with ID: %s. marking resource as gone\n", codeID)
-		_ = d.Set("docker_image", "")
-	} else {
-		_ = d.Set("docker_image", code.Image)
+		d.SetId("")
+		return diags
 	}
-	schedules, _, err := ironClient.Schedules.GetSchedulesWithCode(code.Name)
+	_ = d.Set("docker_image", code.Image)
+	schedules, err := backend.GetSchedules(code.Name)
 	if err != nil {
-		return diag.FromErr(fmt.Errorf("resourceFunctionRead.GetSchedulesWithCode: %w", err))
+		return diag.FromErr(fmt.Errorf("resourceFunctionRead.GetSchedules: %w", err))
 	}
 
-	// Check schedules
-	if len(*schedules) == 0 {
-		_, _ = config.Debug("no schedules found for code '%s'. marking resource as gone\n", code.Name)
-		_ = d.Set("docker_image", "")
-		return diags
+	// Forget trigger schedules which were removed outside of Terraform, so they are recreated
+	existing := make(map[string]bool)
+	for _, s := range schedules {
+		existing[s.ID] = true
+	}
+	triggerIDs := make(map[string]string)
+	for name, v := range d.Get(triggerSchedulesField).(map[string]interface{}) {
+		if id, _ := v.(string); existing[id] {
+			triggerIDs[name] = id
+		}
 	}
-	_, _ = config.Debug("ProjectID: %v\nSignature: %v\nCode: %v\nSchedules: %d\n", ironConfig.ProjectID, signature, codeID, len(*schedules))
-	return diags
+	_ = d.Set(triggerSchedulesField, triggerIDs)
+
+	_, _ = config.Debug("Cluster: %v\nSignature: %v\nCode: %v\nSchedules: %d\n", backend.ClusterID(), signature, codeID, len(schedules))
+	return append(diags, readFunctionSchedules(config, backend, d, schedules)...)
 }
 
 func resourceFunctionCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
-	ironClient, ironConfig, modConfig, err := newIronClient(d, m)
+	backend, err := newFunctionBackend(d, m)
 	if err != nil {
 		return diag.FromErr(err)
 	}
@@ -248,38 +271,36 @@ func resourceFunctionCreate(ctx context.Context, d *schema.ResourceData, m inter
 	name := d.Get("name").(string)
 	dockerImage := d.Get("docker_image").(string)
 	if _, ok := d.GetOk("docker_credentials"); ok {
-		if _, err := dockerLogin(ironClient, d); err != nil {
+		if _, err := dockerLogin(backend, d); err != nil {
 			return diag.FromErr(err)
 		}
 	}
 	signature := strings.Replace(uuid.New().String(), "-", "", -1)
 
-	if ironConfig == nil || len(ironConfig.ClusterInfo) == 0 {
-		return diag.FromErr(fmt.Errorf("invalid iron config: %v", ironConfig))
-	}
 	codeName := fmt.Sprintf("%s-%s", name, signature)
-	createdCode, resp, err := ironClient.Codes.CreateOrUpdateCode(iron.Code{
-		Name:      codeName,
-		Image:     dockerImage,
-		ProjectID: ironConfig.ProjectID,
-	})
+	createdCode, err := backend.CreateCode(codeName, dockerImage)
 	if err != nil {
 		return diag.FromErr(err)
 	}
-	if resp.StatusCode == http.StatusNotFound {
-		return diag.FromErr(fmt.Errorf("code %s not found", dockerImage))
+	diags := createSchedules(backend, d, codeName, createdCode.ID, signature)
+	if len(diags) > 0 {
+		return diags
 	}
-	diags := createSchedules(ironClient, ironConfig, *modConfig, d, codeName, createdCode.ID, signature)
+	ids, diags := reconcileTriggers(backend, d, codeName)
 	if len(diags) > 0 {
+		_ = backend.DeleteCode(createdCode.ID)
+		d.SetId("")
 		return diags
 	}
+	_ = d.Set(triggerSchedulesField, ids)
 
-	_ = d.Set("token", (*modConfig)["siderite_token"])
-	_ = d.Set("auth_type", (*modConfig)["siderite_auth_type"])
+	gateway := backend.Gateway()
+	_ = d.Set("token", gateway.Token)
+	_ = d.Set("auth_type", gateway.AuthType)
 	return resourceFunctionRead(ctx, d, m)
 }
 
-func createSchedules(ironClient *iron.Client, ironConfig *iron.Config, modConfig map[string]string, d *schema.ResourceData, codeName, codeID, signature string) diag.Diagnostics {
+func createSchedules(backend FunctionBackend, d *schema.ResourceData, codeName, codeID, signature string) diag.Diagnostics {
 	var diags diag.Diagnostics
 
 	taskType := "schedule"
@@ -293,15 +314,15 @@ func createSchedules(ironClient *iron.Client, ironConfig *iron.Config, modConfig
 	if schedule != nil && schedule.CRON != nil {
 		taskType = "cron"
 	}
-	encryptedSyncPayload, encryptedAsyncPayload, err := preparePayloads(taskType, modConfig, d, ironConfig)
+	encryptedSyncPayload, encryptedAsyncPayload, err := preparePayloads(taskType, backend, d)
 	if err != nil {
-		_, _, _ = ironClient.Codes.DeleteCode(codeID)
+		_ = backend.DeleteCode(codeID)
 		return diag.FromErr(err)
 	}
 	timeout := d.Get("timeout").(int)
 	startAt := time.Now().Add(aLongTime * time.Second)
-	var syncSchedule *iron.Schedule
-	var asyncSchedule *iron.Schedule
+	var syncSchedule *FunctionSchedule
+	var asyncSchedule *FunctionSchedule
 	switch taskType {
 	case "cron":
 		cfg := siderite.CronPayload{
@@ -310,20 +331,16 @@ func createSchedules(ironClient *iron.Client, ironConfig *iron.Config, modConfig
 			Timeout:          schedule.Timeout,
 		}
 		jsonPayload, _ := json.Marshal(cfg)
-		cronSchedule := iron.Schedule{
+		cronSchedule := FunctionSchedule{
 			CodeName: codeName,
 			Payload:  string(jsonPayload),
-			Cluster:  ironConfig.ClusterInfo[0].ClusterID,
+			Cluster:  backend.ClusterID(),
 			StartAt:  &startAt,
 			RunEvery: aLongTime,
 		}
-		_, resp, err := ironClient.Schedules.CreateSchedule(cronSchedule)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			if err == nil {
-				return diag.FromErr(fmt.Errorf("create CRON schedule failed with code %d", resp.StatusCode))
-			}
-			return diag.FromErr(err)
+		if _, err := backend.CreateSchedule(cronSchedule); err != nil {
+			_ = backend.DeleteCode(codeID)
+			return diag.FromErr(fmt.Errorf("create CRON schedule: %w", err))
 		}
 		d.SetId(fmt.Sprintf("%s-%s", codeID, signature))
 	case "function":
@@ -332,44 +349,34 @@ func createSchedules(ironClient *iron.Client, ironConfig *iron.Config, modConfig
 			Type:             "sync",
 		}
 		jsonPayload, _ := json.Marshal(cfg)
-		syncSchedule = &iron.Schedule{
+		syncSchedule = &FunctionSchedule{
 			CodeName: codeName,
 			Payload:  string(jsonPayload),
-			Cluster:  ironConfig.ClusterInfo[0].ClusterID,
+			Cluster:  backend.ClusterID(),
 			StartAt:  &startAt,
 			RunEvery: aLongTime,
 			Timeout:  timeout,
 		}
-		_, resp, err := ironClient.Schedules.CreateSchedule(*syncSchedule)
-		if err != nil {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			return diag.FromErr(err)
-		}
-		if resp.StatusCode != http.StatusOK {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			return diag.FromErr(fmt.Errorf("creating sync schedule failed with code %d", resp.StatusCode))
+		if _, err := backend.CreateSchedule(*syncSchedule); err != nil {
+			_ = backend.DeleteCode(codeID)
+			return diag.FromErr(fmt.Errorf("creating sync schedule: %w", err))
 		}
 		cfg = siderite.CronPayload{
 			EncryptedPayload: encryptedAsyncPayload,
 			Type:             "async",
 		}
 		jsonPayload, _ = json.Marshal(cfg)
-		asyncSchedule = &iron.Schedule{
+		asyncSchedule = &FunctionSchedule{
 			CodeName: codeName,
 			Payload:  string(jsonPayload),
-			Cluster:  ironConfig.ClusterInfo[0].ClusterID,
+			Cluster:  backend.ClusterID(),
 			StartAt:  &startAt,
 			RunEvery: aLongTime,
 			Timeout:  timeout,
 		}
-		_, resp, err = ironClient.Schedules.CreateSchedule(*asyncSchedule)
-		if err != nil {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			return diag.FromErr(err)
-		}
-		if resp.StatusCode != http.StatusOK {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			return diag.FromErr(fmt.Errorf("creating async schedule failed with code %d", resp.StatusCode))
+		if _, err := backend.CreateSchedule(*asyncSchedule); err != nil {
+			_ = backend.DeleteCode(codeID)
+			return diag.FromErr(fmt.Errorf("creating async schedule: %w", err))
 		}
 		d.SetId(fmt.Sprintf("%s-%s", codeID, signature))
 	case "schedule":
@@ -378,65 +385,68 @@ func createSchedules(ironClient *iron.Client, ironConfig *iron.Config, modConfig
 		}
 		schedule.Iron.CodeName = codeName
 		schedule.Iron.Payload = encryptedSyncPayload
-		schedule.Iron.Cluster = ironConfig.ClusterInfo[0].ClusterID
-		_, resp, err := ironClient.Schedules.CreateSchedule(*schedule.Iron)
-		if err != nil || resp.StatusCode != http.StatusOK {
-			_, _, _ = ironClient.Codes.DeleteCode(codeID)
-			if err == nil {
-				return diag.FromErr(fmt.Errorf("create schedule failed with code %d", resp.StatusCode))
-			}
+		schedule.Iron.Cluster = backend.ClusterID()
+		if _, err := backend.CreateSchedule(*schedule.Iron); err != nil {
+			_ = backend.DeleteCode(codeID)
 			return diag.FromErr(err)
 		}
 		d.SetId(fmt.Sprintf("%s-%s", codeID, signature))
 	}
+	endpoint, asyncEndpoint := backend.Endpoints(codeID)
 	if syncSchedule != nil {
-		_ = d.Set("endpoint", fmt.Sprintf("https://%s/function/%s", (modConfig)["siderite_upstream"], codeID))
+		_ = d.Set("endpoint", endpoint)
 	}
 	if asyncSchedule != nil {
-		_ = d.Set("async_endpoint", fmt.Sprintf("https://%s/async-function/%s", (modConfig)["siderite_upstream"], codeID))
+		_ = d.Set("async_endpoint", asyncEndpoint)
 	}
 	return diags
 }
 
-func preparePayloads(taskType string, modConfig map[string]string, d *schema.ResourceData, config *iron.Config) (string, string, error) {
+func preparePayloads(taskType string, backend FunctionBackend, d *schema.ResourceData) (string, string, error) {
+	command := functionCommand(d.Get("command"))
+	environment := getEnvironment(d)
+
+	syncPayload, err := encryptFunctionPayload(backend, taskType, "sync", command, environment)
+	if err != nil {
+		return "", "", fmt.Errorf("preparePayloads.sync: %w", err)
+	}
+	asyncPayload, err := encryptFunctionPayload(backend, taskType, "async", command, environment)
+	if err != nil {
+		return "", "", fmt.Errorf("preparePayloads.async: %w", err)
+	}
+	return syncPayload, asyncPayload, nil
+}
+
+// functionCommand returns the configured command, or the default /app/server
+func functionCommand(raw interface{}) []string {
 	command := []string{"/app/server"}
-	if list, ok := d.Get("command").([]interface{}); ok && len(list) > 0 {
+	if list, ok := raw.([]interface{}); ok && len(list) > 0 {
 		command = []string{}
 		for i := 0; i < len(list); i++ {
 			command = append(command, list[i].(string))
 		}
 	}
-	environment := getEnvironment(d)
+	return command
+}
 
+// encryptFunctionPayload returns the encrypted siderite payload which runs the command
+func encryptFunctionPayload(backend FunctionBackend, taskType, mode string, command []string, environment map[string]string) (string, error) {
+	gateway := backend.Gateway()
 	payload := siderite.Payload{
 		Version:  "1",
 		Type:     taskType,
-		Token:    modConfig["siderite_token"],
-		Upstream: modConfig["siderite_upstream"],
-		Auth:     modConfig["siderite_auth_type"],
+		Token:    gateway.Token,
+		Upstream: gateway.Upstream,
+		Auth:     gateway.AuthType,
 		Cmd:      command,
 		Env:      environment,
-		Mode:     "sync",
+		Mode:     mode,
 	}
 	payloadJSON, err := json.Marshal(&payload)
 	if err != nil {
-		return "", "", fmt.Errorf("preparePayload: %w", err)
+		return "", fmt.Errorf("preparePayload: %w", err)
 	}
-	syncPayload, err := iron.EncryptPayload([]byte(config.ClusterInfo[0].Pubkey), payloadJSON)
-	if err != nil {
-		return "", "", fmt.Errorf("preparePayloads.sync: %w", err)
-	}
-	// Async
-	payload.Mode = "async"
-	payloadJSON, err = json.Marshal(&payload)
-	if err != nil {
-		return "", "", fmt.Errorf("preparePayload: %w", err)
-	}
-	asyncPayload, err := iron.EncryptPayload([]byte(config.ClusterInfo[0].Pubkey), payloadJSON)
-	if err != nil {
-		return "", "", fmt.Errorf("preparePayloads.async: %w", err)
-	}
-	return syncPayload, asyncPayload, nil
+	return backend.EncryptPayload(payloadJSON)
 }
 
 func getEnvironment(d *schema.ResourceData) map[string]string {
@@ -453,7 +463,7 @@ func getEnvironment(d *schema.ResourceData) map[string]string {
 	return environment
 }
 
-func dockerLogin(ironClient *iron.Client, d *schema.ResourceData) (bool, error) {
+func dockerLogin(backend FunctionBackend, d *schema.ResourceData) (bool, error) {
 	name := d.Get("name").(string)
 	dockerImage := d.Get("docker_image").(string)
 	ref, err := reference.ParseNormalizedNamed(dockerImage)
@@ -471,21 +481,21 @@ func dockerLogin(ironClient *iron.Client, d *schema.ResourceData) (bool, error)
 	vv := v.(map[string]interface{})
 	username := vv["username"].(string)
 	password := vv["password"].(string)
-	ok, _, err = ironClient.Codes.DockerLogin(iron.DockerCredentials{
+	err = backend.DockerLogin(FunctionDockerCredentials{
 		Email:         fmt.Sprintf("terraform-%s@localhost.localdomain", name),
 		Username:      username,
 		Password:      password,
 		ServerAddress: registry,
 	})
-	if !ok {
-		return false, fmt.Errorf("invalid docker credentials: %w", err)
+	if err != nil {
+		return false, err
 	}
 	return true, nil
 }
 
 type functionSchedule struct {
 	Timeout int
-	Iron    *iron.Schedule
+	Iron    *FunctionSchedule
 	CRON    *string
 }
 
@@ -507,7 +517,7 @@ func getSchedule(d *schema.ResourceData) (*functionSchedule, bool, error) {
 		if err != nil {
 			return nil, false, err
 		}
-		ironSchedule := iron.Schedule{
+		ironSchedule := FunctionSchedule{
 			StartAt:  firstRun,
 			RunEvery: runEvery,
 			Timeout:  timeout,
@@ -561,67 +571,3 @@ func calcRunEvery(runEvery, startAt string) (int, *time.Time, error) {
 	}
 	return seconds, &firstRun, nil
 }
-
-func newIronClient(d *schema.ResourceData, m interface{}) (*iron.Client, *iron.Config, *map[string]string, error) {
-	c := m.(*Config)
-	backend, ok := d.Get("backend").([]interface{})
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("expected array of 'backend' config")
-	}
-	backendResource, ok := backend[0].(map[string]interface{})
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("unexpected backend format")
-	}
-
-	configMap, ok := backendResource["credentials"].(map[string]interface{})
-	if !ok {
-		return nil, nil, nil, fmt.Errorf("invalid or missing iron config credentials")
-	}
-	config := make(map[string]string)
-	for k, v := range configMap {
-		if str, ok := v.(string); ok {
-			config[k] = str
-		}
-	}
-	backendType := config["type"]
-	if !(backendType == "siderite" || backendType == "ferrite") {
-		return nil, nil, nil, fmt.Errorf("expected backend type of ['siderite' | 'ferrite']")
-	}
-	// Bootstrap ferrite
-	if backendType == "ferrite" {
-		token := config["token"]
-		bootstrap, err := server.Bootstrap(config["base_url"], token)
-		if err != nil {
-			return nil, nil, nil, fmt.Errorf("error bootstrapping ferrite: %w", err)
-		}
-		// Inject bootstrap data
-		config["project"] = bootstrap.ProjectID
-		config["project_id"] = bootstrap.ProjectID
-		config["cluster_info_0_cluster_id"] = bootstrap.ClusterID
-		config["cluster_info_0_pubkey"] = bootstrap.PublicKey
-	}
-	ironConfig := iron.Config{
-		BaseURL:   config["base_url"],
-		Email:     config["email"],
-		Password:  config["password"],
-		Project:   config["project"],
-		ProjectID: config["project_id"],
-		Token:     config["token"],
-		UserID:    config["user_id"],
-		DebugLog:  c.DebugLog,
-		ClusterInfo: []iron.ClusterInfo{
-			{
-				ClusterID:   config["cluster_info_0_cluster_id"],
-				ClusterName: config["cluster_info_0_cluster_name"],
-				Pubkey:      config["cluster_info_0_pubkey"],
-				UserID:      config["cluster_info_0_user_id"],
-			},
-		},
-	}
-
-	client, err := iron.NewClient(&ironConfig)
-	if err != nil {
-		return nil, nil, nil, fmt.Errorf("iron.NewClient: %w", err)
-	}
-	return client, &ironConfig, &config, nil
-}
@@ -0,0 +1,326 @@
+package hsdp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+)
+
+const (
+	// functionCallbackHeader tells the gateway where to post the result of an async invocation.
+	// The gateway stores it with the request, siderite posts the response of the function there
+	functionCallbackHeader = "X-Callback-URL"
+
+	invocationStatusCompleted = "completed"
+	invocationStatusAccepted  = "accepted"
+)
+
+func resourceFunctionInvocation() *schema.Resource {
+	return &schema.Resource{
+		Description: `The ` + "`hsdp_function_invocation`" + ` resource calls a function during apply, e.g. to run a migration or seed step.
+The ` + "`triggers`" + ` argument allows specifying an arbitrary set of values that, when changed, will cause the function to be invoked again.`,
+
+		CreateContext: resourceFunctionInvocationCreate,
+		ReadContext:   resourceFunctionInvocationRead,
+		UpdateContext: resourceFunctionInvocationUpdate,
+		DeleteContext: resourceFunctionInvocationDelete,
+		CustomizeDiff: customizeFunctionInvocationDiff,
+
+		Schema: map[string]*schema.Schema{
+			"triggers": {
+				Description: "A map of arbitrary strings that, when changed, will invoke the function again.",
+				Type:        schema.TypeMap,
+				Optional:    true,
+				ForceNew:    true,
+				Elem:        &schema.Schema{Type: schema.TypeString},
+			},
+			"endpoint": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			"payload": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "{}",
+				ValidateFunc: validation.StringIsJSON,
+			},
+			"auth_type": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "none",
+				ValidateFunc: validation.StringInSlice([]string{"none", "token", "iam"}, false),
+			},
+			"token": {
+				Type:      schema.TypeString,
+				Optional:  true,
+				Sensitive: true,
+			},
+			"async": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"wait": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				ForceNew: true,
+				Default:  false,
+			},
+			"callback_listen": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"callback_url": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.IsURLWithHTTPorHTTPS,
+			},
+			"timeout": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      300,
+				ValidateFunc: validation.IntAtLeast(1),
+			},
+			"status": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"status_code": {
+				Type:     schema.TypeInt,
+				Computed: true,
+			},
+			"output": {
+				Type:      schema.TypeString,
+				Computed:  true,
+				Sensitive: true,
+			},
+		},
+	}
+}
+
+// functionRequest describes a call of a function endpoint
+type functionRequest struct {
+	Endpoint string
+	Payload  string
+	AuthType string
+	Token    string
+	Timeout  time.Duration
+	Async    bool
+	// Wait for the result of an async invocation, which the gateway posts to CallbackURL.
+	// CallbackURL must reach the listener on CallbackListen, the functions run on Iron
+	Wait           bool
+	CallbackListen string
+	CallbackURL    string
+}
+
+// expandFunctionRequest reads the request from the invocation resource
+func expandFunctionRequest(d *schema.ResourceData) functionRequest {
+	return functionRequest{
+		Endpoint:       d.Get("endpoint").(string),
+		Payload:        d.Get("payload").(string),
+		AuthType:       d.Get("auth_type").(string),
+		Token:          d.Get("token").(string),
+		Timeout:        time.Duration(d.Get("timeout").(int)) * time.Second,
+		Async:          d.Get("async").(bool),
+		Wait:           d.Get("wait").(bool),
+		CallbackListen: d.Get("callback_listen").(string),
+		CallbackURL:    d.Get("callback_url").(string),
+	}
+}
+
+// customizeFunctionInvocationDiff requires a reachable callback when waiting for an async result,
+// as Iron can not post it to a listener on the machine running Terraform
+func customizeFunctionInvocationDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.Get("async").(bool) || !d.Get("wait").(bool) {
+		return nil
+	}
+	for _, field := range []string{"callback_url", "callback_listen"} {
+		if d.NewValueKnown(field) && d.Get(field).(string) == "" {
+			return fmt.Errorf("waiting for an async invocation requires %s", field)
+		}
+	}
+	return nil
+}
+
+// functionInvocation holds the result of calling a function
+type functionInvocation struct {
+	Status     string
+	StatusCode int
+	Output     string
+}
+
+// functionAuthorization returns the Authorization header for the auth_type of the function
+func functionAuthorization(config *Config, authType, token string) (string, error) {
+	switch authType {
+	case "token":
+		if token == "" {
+			return "", fmt.Errorf("auth_type 'token' requires a token")
+		}
+		return "Token " + token, nil
+	case "iam":
+		client, err := config.IAMClient()
+		if err != nil {
+			return "", fmt.Errorf("auth_type 'iam': %w", err)
+		}
+		if client == nil || client.Token() == "" {
+			return "", fmt.Errorf("auth_type 'iam' requires an IAM login")
+		}
+		return "Bearer " + client.Token(), nil
+	}
+	return "", nil
+}
+
+// callbackListener receives the result which the gateway posts after an async invocation
+type callbackListener struct {
+	listener net.Listener
+	server   *http.Server
+	results  chan string
+}
+
+func newCallbackListener(address string) (*callbackListener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("callback listener: %w", err)
+	}
+	c := &callbackListener{
+		listener: listener,
+		results:  make(chan string, 1),
+	}
+	c.server = &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		select {
+		case c.results <- string(body):
+		default:
+		}
+		w.WriteHeader(http.StatusOK)
+	})}
+	go func() { _ = c.server.Serve(listener) }()
+	return c, nil
+}
+
+func (c *callbackListener) Close() {
+	_ = c.server.Close()
+}
+
+// invokeFunction calls the function endpoint with the payload. Async invocations which
+// wait for the result receive it on a callback listener
+func invokeFunction(ctx context.Context, config *Config, request functionRequest) (*functionInvocation, error) {
+	ctx, cancel := context.WithTimeout(ctx, request.Timeout)
+	defer cancel()
+
+	endpoint := request.Endpoint
+	if endpoint == "" {
+		// e.g. a scheduled function, which is not served by the gateway
+		return nil, fmt.Errorf("function has no endpoint to invoke")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewBufferString(request.Payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	authorization, err := functionAuthorization(config, request.AuthType, request.Token)
+	if err != nil {
+		return nil, err
+	}
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+
+	wait := request.Async && request.Wait
+	var callback *callbackListener
+	if wait {
+		if request.CallbackURL == "" || request.CallbackListen == "" {
+			return nil, fmt.Errorf("waiting for an async invocation requires callback_url and callback_listen")
+		}
+		callback, err = newCallbackListener(request.CallbackListen)
+		if err != nil {
+			return nil, err
+		}
+		defer callback.Close()
+	}
+	if request.Async && request.CallbackURL != "" {
+		req.Header.Set(functionCallbackHeader, request.CallbackURL)
+	}
+
+	_, _ = config.Debug("invoking function %s (async=%t)\n", endpoint, request.Async)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("invoking %s: %w", endpoint, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response of %s: %w", endpoint, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("invoking %s failed with code %d: %s", endpoint, resp.StatusCode, string(body))
+	}
+	result := &functionInvocation{
+		Status:     invocationStatusCompleted,
+		StatusCode: resp.StatusCode,
+		Output:     string(body),
+	}
+	if !request.Async {
+		return result, nil
+	}
+	if !wait {
+		result.Status = invocationStatusAccepted
+		return result, nil
+	}
+	select {
+	case output := <-callback.results:
+		result.Output = output
+		return result, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out after %s waiting for the result of %s on %s", request.Timeout, endpoint, request.CallbackURL)
+	}
+}
+
+func resourceFunctionInvocationCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	config := m.(*Config)
+
+	result, err := invokeFunction(ctx, config, expandFunctionRequest(d))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	_ = d.Set("status", result.Status)
+	_ = d.Set("status_code", result.StatusCode)
+	_ = d.Set("output", result.Output)
+	d.SetId(uuid.New().String())
+	return diags
+}
+
+func resourceFunctionInvocationRead(_ context.Context, _ *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	return diags
+}
+
+// resourceFunctionInvocationUpdate only stores the new token or timeout, changes which
+// should invoke the function again force a replacement
+func resourceFunctionInvocationUpdate(_ context.Context, _ *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	return diags
+}
+
+func resourceFunctionInvocationDelete(_ context.Context, d *schema.ResourceData, _ interface{}) diag.Diagnostics {
+	var diags diag.Diagnostics
+	d.SetId("")
+	return diags
+}
@@ -0,0 +1,123 @@
+package hsdp
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionInvocation(t *testing.T) {
+	var callbackURL string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.URL.Path {
+		case "/function/abc":
+			_, _ = w.Write(append([]byte("sync:"), body...))
+		case "/async-function/abc":
+			callbackURL = r.Header.Get(functionCallbackHeader)
+			w.WriteHeader(http.StatusAccepted)
+			if callbackURL != "" {
+				go func() {
+					resp, err := http.Post(callbackURL, "application/json", bytes.NewReader(append([]byte("async:"), body...)))
+					if err == nil {
+						_ = resp.Body.Close()
+					}
+				}()
+			}
+		}
+	}))
+	defer server.Close()
+
+	r := resourceFunctionInvocation()
+	meta := &Config{}
+	invoke := func(raw map[string]interface{}) (*schema.ResourceData, bool) {
+		d := schema.TestResourceDataRaw(t, r.Schema, raw)
+		diags := r.CreateContext(context.Background(), d, meta)
+		return d, !diags.HasError()
+	}
+
+	d, ok := invoke(map[string]interface{}{
+		"endpoint":  server.URL + "/function/abc",
+		"payload":   `{"seed":true}`,
+		"auth_type": "token",
+		"token":     "s3cr3t",
+	})
+	if assert.True(t, ok) {
+		assert.Equal(t, invocationStatusCompleted, d.Get("status"))
+		assert.Equal(t, http.StatusOK, d.Get("status_code"))
+		assert.Equal(t, `sync:{"seed":true}`, d.Get("output"))
+		assert.NotEmpty(t, d.Id())
+	}
+
+	// The callback URL reaches the listener, e.g. through a tunnel
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.Nil(t, err) {
+		return
+	}
+	listen := listener.Addr().String()
+	_ = listener.Close()
+	d, ok = invoke(map[string]interface{}{
+		"endpoint":        server.URL + "/async-function/abc",
+		"auth_type":       "token",
+		"token":           "s3cr3t",
+		"async":           true,
+		"wait":            true,
+		"callback_listen": listen,
+		"callback_url":    "http://" + listen + "/",
+	})
+	if assert.True(t, ok) {
+		assert.Equal(t, "http://"+listen+"/", callbackURL)
+		assert.Equal(t, invocationStatusCompleted, d.Get("status"))
+		assert.Equal(t, http.StatusAccepted, d.Get("status_code"))
+		assert.Equal(t, `async:{}`, d.Get("output"))
+	}
+
+	callbackURL = ""
+	d, ok = invoke(map[string]interface{}{
+		"endpoint":  server.URL + "/async-function/abc",
+		"auth_type": "token",
+		"token":     "s3cr3t",
+		"async":     true,
+	})
+	if assert.True(t, ok) {
+		assert.Equal(t, invocationStatusAccepted, d.Get("status"))
+		assert.Empty(t, callbackURL)
+	}
+
+	_, ok = invoke(map[string]interface{}{
+		"endpoint":  server.URL + "/function/abc",
+		"auth_type": "token",
+		"token":     "wrong",
+	})
+	assert.False(t, ok)
+}
+
+func TestFunctionInvocationWaitRequiresCallback(t *testing.T) {
+	r := resourceFunctionInvocation()
+	raw := map[string]interface{}{
+		"endpoint": "https://gateway.local/async-function/abc",
+		"async":    true,
+		"wait":     true,
+	}
+	_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), &Config{})
+	assert.NotNil(t, err)
+
+	raw["callback_url"] = "https://tunnel.local/"
+	raw["callback_listen"] = "127.0.0.1:8090"
+	_, err = r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), &Config{})
+	assert.Nil(t, err)
+
+	assert.True(t, r.Schema["output"].Sensitive)
+}
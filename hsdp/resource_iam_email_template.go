@@ -3,13 +3,116 @@ package hsdp
 import (
 	"context"
 	"encoding/base64"
+	"fmt"
+	"io"
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
 	"github.com/philips-software/go-hsdp-api/iam"
+	"golang.org/x/net/html"
 )
 
+const (
+	emailTemplateMaxSubjectLength = 256
+	emailTemplateMaxMessageSize   = 64 * 1024
+)
+
+// emailTemplateCommonPlaceholders are supported by all email template types
+var emailTemplateCommonPlaceholders = []string{
+	"user.email",
+	"user.userName",
+	"user.givenName",
+	"user.familyName",
+}
+
+// emailTemplatePlaceholders lists the additional placeholders per email template type
+var emailTemplatePlaceholders = map[string][]string{
+	"ACCOUNT_ALREADY_VERIFIED": {},
+	"ACCOUNT_UNLOCKED":         {},
+	"ACCOUNT_VERIFICATION":     {"link.verification", "template.linkExpiryPeriod"},
+	"MFA_DISABLED":             {},
+	"MFA_ENABLED":              {},
+	"PASSWORD_CHANGED":         {},
+	"PASSWORD_EXPIRY":          {"link.passwordChange", "password.expiresAfterPeriod"},
+	"PASSWORD_FAILED_ATTEMPTS": {"user.lockoutPeriod"},
+	"PASSWORD_RECOVERY":        {"link.passwordReset"},
+}
+
+func emailTemplateTypes() []string {
+	types := make([]string, 0, len(emailTemplatePlaceholders))
+	for t := range emailTemplatePlaceholders {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func allowedEmailTemplatePlaceholders(templateType string) []string {
+	return append(append([]string{}, emailTemplateCommonPlaceholders...), emailTemplatePlaceholders[templateType]...)
+}
+
+// validateEmailTemplate checks the subject and message of a template against
+// the placeholders supported by its type, the size limits and, for HTML
+// templates, whether the markup can be tokenized
+func validateEmailTemplate(templateType, format, subject, message string) error {
+	if len(subject) > emailTemplateMaxSubjectLength {
+		return fmt.Errorf("subject is too long (%d > %d)", len(subject), emailTemplateMaxSubjectLength)
+	}
+	if len(message) > emailTemplateMaxMessageSize {
+		return fmt.Errorf("message is too large (%d > %d bytes)", len(message), emailTemplateMaxMessageSize)
+	}
+	// Placeholders of template types unknown to the provider can not be checked
+	if _, ok := emailTemplatePlaceholders[templateType]; ok {
+		allowed := allowedEmailTemplatePlaceholders(templateType)
+		if err := validatePlaceholders(subject, allowed); err != nil {
+			return fmt.Errorf("subject of %s template: %w", templateType, err)
+		}
+		if err := validatePlaceholders(message, allowed); err != nil {
+			return fmt.Errorf("message of %s template: %w", templateType, err)
+		}
+	}
+	if format == "HTML" {
+		if err := validateHTML(message); err != nil {
+			return fmt.Errorf("message of %s template: %w", templateType, err)
+		}
+	}
+	return nil
+}
+
+// validateHTML reports errors of the HTML tokenizer. Tags are not balanced, as HTML5
+// allows leaving out end tags, e.g. of <p> and <li>
+func validateHTML(markup string) error {
+	z := html.NewTokenizer(strings.NewReader(markup))
+	for {
+		if z.Next() == html.ErrorToken {
+			if z.Err() != io.EOF {
+				return fmt.Errorf("invalid HTML: %w", z.Err())
+			}
+			return nil
+		}
+	}
+}
+
+// validateEmailTemplateDiff only checks templates which change, so templates which are
+// already in use keep planning when the checks change
+func validateEmailTemplateDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	changed := d.Id() == ""
+	for _, field := range []string{"type", "format", "subject", "message"} {
+		if !d.NewValueKnown(field) {
+			return nil
+		}
+		changed = changed || d.HasChange(field)
+	}
+	if !changed {
+		return nil
+	}
+	return validateEmailTemplate(d.Get("type").(string), d.Get("format").(string),
+		d.Get("subject").(string), d.Get("message").(string))
+}
+
 func resourceIAMEmailTemplate() *schema.Resource {
 	return &schema.Resource{
 		Importer: &schema.ResourceImporter{
@@ -19,6 +122,7 @@ func resourceIAMEmailTemplate() *schema.Resource {
 		CreateContext: resourceIAMEmailTemplateCreate,
 		ReadContext:   resourceIAMEmailTemplateRead,
 		DeleteContext: resourceIAMEmailTemplateDelete,
+		CustomizeDiff: validateEmailTemplateDiff,
 
 		Schema: map[string]*schema.Schema{
 			"managing_organization": {
@@ -27,9 +131,10 @@ func resourceIAMEmailTemplate() *schema.Resource {
 				ForceNew: true,
 			},
 			"type": {
-				Type:     schema.TypeString,
-				Required: true,
-				ForceNew: true,
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: warnUnknownValue(emailTemplateTypes()),
 			},
 			"from": {
 				Type:             schema.TypeString,
@@ -38,10 +143,11 @@ func resourceIAMEmailTemplate() *schema.Resource {
 				DiffSuppressFunc: suppressDefault,
 			},
 			"format": {
-				Type:     schema.TypeString,
-				Optional: true,
-				ForceNew: true,
-				Default:  "HTML",
+				Type:         schema.TypeString,
+				Optional:     true,
+				ForceNew:     true,
+				Default:      "HTML",
+				ValidateFunc: warnUnknownValue([]string{"HTML"}),
 			},
 			"subject": {
 				Type:     schema.TypeString,
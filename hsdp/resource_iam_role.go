@@ -3,7 +3,9 @@ package hsdp
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
 
@@ -11,6 +13,77 @@ import (
 	"github.com/philips-software/go-hsdp-api/iam"
 )
 
+// dangerousPermissions lists permissions which deserve a second look when granted
+var dangerousPermissions = map[string]string{
+	"CLIENT.SCOPES": "it can only be granted through an HSDP support ticket, so removal cannot be undone from Terraform",
+	"ROLE.WRITE":    "holders can add any permission to roles, including their own",
+	"GROUP.WRITE":   "holders can assign roles and add members to groups",
+}
+
+// sensitivePermissionWarnings warns about the sensitive permissions among the added permissions
+func sensitivePermissionWarnings(added []string) diag.Diagnostics {
+	var diags diag.Diagnostics
+	for _, permission := range added {
+		if reason, ok := dangerousPermissions[permission]; ok {
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("role grants sensitive permission %s", permission),
+				Detail:   fmt.Sprintf("Granting %s should be done with care: %s", permission, reason),
+			})
+		}
+	}
+	return diags
+}
+
+// validateRolePermissionsDiff checks the role permissions against the IAM
+// permission catalog so typos surface during plan instead of halfway an apply.
+// Removing CLIENT.SCOPES while ticket_protection is on fails the plan
+func validateRolePermissionsDiff(_ context.Context, d *schema.ResourceDiff, m interface{}) error {
+	if !d.HasChange("permissions") || !d.NewValueKnown("permissions") {
+		return nil
+	}
+	o, n := d.GetChange("permissions")
+	oldList := expandStringList(o.(*schema.Set).List())
+	newList := expandStringList(n.(*schema.Set).List())
+	if d.Id() != "" && d.Get("ticket_protection").(bool) && containsString(difference(oldList, newList), "CLIENT.SCOPES") {
+		return fmt.Errorf("refusing to remove CLIENT.SCOPES permission, set ticket_protection to `false` to override")
+	}
+	for _, p := range difference(newList, oldList) {
+		if reason, ok := dangerousPermissions[p]; ok {
+			log.Printf("[WARN] role %s grants sensitive permission %s: %s", d.Get("name"), p, reason)
+		}
+	}
+	config, ok := m.(*Config)
+	if !ok {
+		return nil
+	}
+	catalog, err := config.IAMPermissionCatalog()
+	if err != nil {
+		// Do not block plans when the catalog is unavailable, apply will tell
+		log.Printf("[WARN] skipping role permission validation, IAM permission catalog unavailable: %v", err)
+		return nil
+	}
+	known := make(map[string]bool, len(catalog))
+	for _, p := range catalog {
+		known[p] = true
+	}
+	var unknown []string
+	for _, p := range expandStringList(d.Get("permissions").(*schema.Set).List()) {
+		if known[p] {
+			continue
+		}
+		if suggestions := nearMatches(p, catalog, 3); len(suggestions) > 0 {
+			unknown = append(unknown, fmt.Sprintf("%s (did you mean %s?)", p, strings.Join(suggestions, ", ")))
+			continue
+		}
+		unknown = append(unknown, p)
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unknown IAM permission(s): %s", strings.Join(unknown, "; "))
+	}
+	return nil
+}
+
 func resourceIAMRole() *schema.Resource {
 	return &schema.Resource{
 		Importer: &schema.ResourceImporter{
@@ -21,6 +94,7 @@ func resourceIAMRole() *schema.Resource {
 		ReadContext:   resourceIAMRoleRead,
 		UpdateContext: resourceIAMRoleUpdate,
 		DeleteContext: resourceIAMRoleDelete,
+		CustomizeDiff: validateRolePermissionsDiff,
 
 		Schema: map[string]*schema.Schema{
 			"name": {
@@ -99,6 +173,7 @@ func resourceIAMRoleCreate(ctx context.Context, d *schema.ResourceData, meta int
 		_, _, _ = client.Roles.AddRolePermission(*role, p)
 	}
 	d.SetId(role.ID)
+	diags = append(diags, sensitivePermissionWarnings(permissions)...)
 	readDiags := resourceIAMRoleRead(ctx, d, meta)
 	if readDiags != nil {
 		diags = append(diags, readDiags...)
@@ -176,6 +251,7 @@ func resourceIAMRoleUpdate(ctx context.Context, d *schema.ResourceData, m interf
 				}
 			}
 		}
+		diags = append(diags, sensitivePermissionWarnings(toAdd)...)
 
 		// Removals
 		for _, v := range toRemove {
@@ -0,0 +1,292 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+const (
+	roleSharingAPIVersion = "1"
+)
+
+// iamRoleSharingPolicy describes how a role is shared with a target organization
+type iamRoleSharingPolicy struct {
+	InternalID           string    `json:"internalId,omitempty"`
+	SharingPolicy        string    `json:"sharingPolicy"`
+	TargetOrganizationID string    `json:"targetOrganizationId"`
+	SourceOrganizationID string    `json:"sourceOrganizationId,omitempty"`
+	RoleName             string    `json:"roleName,omitempty"`
+	Purpose              string    `json:"purpose,omitempty"`
+	Meta                 *iam.Meta `json:"meta,omitempty"`
+}
+
+func roleSharingPolicyPath(roleID, targetOrgID string) string {
+	path := "authorize/identity/Role/" + roleID + "/$sharing-policy"
+	if targetOrgID != "" {
+		query := url.Values{}
+		query.Set("targetOrganizationId", targetOrgID)
+		path += "?" + query.Encode()
+	}
+	return path
+}
+
+// roleSharingID returns the ID of a sharing, which is the role ID and the target
+// organizations at creation so several sharings of the same role do not collide
+func roleSharingID(roleID string, targets []string) string {
+	sorted := append([]string{}, targets...)
+	sort.Strings(sorted)
+	return roleID + "/" + strings.Join(sorted, ",")
+}
+
+// parseRoleSharingID returns the role ID and target organizations of a sharing ID.
+// IDs without targets are a plain role ID
+func parseRoleSharingID(id string) (string, []string) {
+	parts := strings.SplitN(id, "/", 2)
+	if len(parts) < 2 || parts[1] == "" {
+		return parts[0], nil
+	}
+	return parts[0], strings.Split(parts[1], ",")
+}
+
+func resourceIAMRoleSharing() *schema.Resource {
+	return &schema.Resource{
+		Importer: &schema.ResourceImporter{
+			StateContext: importIAMRoleSharing,
+		},
+
+		CreateContext: resourceIAMRoleSharingCreate,
+		ReadContext:   resourceIAMRoleSharingRead,
+		UpdateContext: resourceIAMRoleSharingUpdate,
+		DeleteContext: resourceIAMRoleSharingDelete,
+
+		Schema: map[string]*schema.Schema{
+			"role_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"sharing_policy": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ValidateFunc: validation.StringInSlice([]string{"Restricted", "AllowChildren", "Denied"}, false),
+			},
+			"target_organization_ids": {
+				Type:     schema.TypeSet,
+				Required: true,
+				MinItems: 1,
+				Elem:     &schema.Schema{Type: schema.TypeString},
+			},
+			"purpose": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"source_organization_id": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"role_name": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+// importIAMRoleSharing accepts either a role ID, which imports all targets the role is shared
+// with, or a role ID and a comma separated list of target organizations
+func importIAMRoleSharing(ctx context.Context, d *schema.ResourceData, m interface{}) ([]*schema.ResourceData, error) {
+	roleID, targets := parseRoleSharingID(d.Id())
+	_ = d.Set("role_id", roleID)
+	if len(targets) > 0 {
+		_ = d.Set("target_organization_ids", targets)
+	}
+	diags := resourceIAMRoleSharingRead(ctx, d, m)
+	if diags.HasError() {
+		return nil, fmt.Errorf("import role sharing %s: %s", d.Id(), diags[0].Summary)
+	}
+	if d.Id() == "" {
+		return nil, fmt.Errorf("role %s is not shared with the given organizations", roleID)
+	}
+	d.SetId(roleSharingID(roleID, expandStringList(d.Get("target_organization_ids").(*schema.Set).List())))
+	return []*schema.ResourceData{d}, nil
+}
+
+func applyRoleSharingPolicy(client *iam.Client, roleID, targetOrgID string, d *schema.ResourceData) error {
+	policy := iamRoleSharingPolicy{
+		SharingPolicy:        d.Get("sharing_policy").(string),
+		TargetOrganizationID: targetOrgID,
+		Purpose:              d.Get("purpose").(string),
+	}
+	err := tryIAMCall(func() (*iam.Response, error) {
+		return iamRequest(client, http.MethodPost, roleSharingPolicyPath(roleID, ""), roleSharingAPIVersion, policy, nil)
+	})
+	if err != nil {
+		return fmt.Errorf("sharing role %s with %s: %w", roleID, targetOrgID, err)
+	}
+	return nil
+}
+
+func removeRoleSharingPolicy(client *iam.Client, roleID, targetOrgID string) error {
+	resp, err := iamRequest(client, http.MethodDelete, roleSharingPolicyPath(roleID, targetOrgID), roleSharingAPIVersion, nil, nil)
+	if err != nil && !(resp != nil && resp.StatusCode == http.StatusNotFound) { // Already revoked
+		return fmt.Errorf("revoking share of role %s with %s: %w", roleID, targetOrgID, err)
+	}
+	return nil
+}
+
+func resourceIAMRoleSharingCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	roleID := d.Get("role_id").(string)
+	targets := expandStringList(d.Get("target_organization_ids").(*schema.Set).List())
+
+	for i, target := range targets {
+		if err := applyRoleSharingPolicy(client, roleID, target, d); err != nil {
+			// Roll back the shares created so far
+			for _, created := range targets[:i] {
+				_ = removeRoleSharingPolicy(client, roleID, created)
+			}
+			return diag.FromErr(err)
+		}
+	}
+	d.SetId(roleSharingID(roleID, targets))
+	return resourceIAMRoleSharingRead(ctx, d, m)
+}
+
+func resourceIAMRoleSharingRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	roleID := d.Get("role_id").(string)
+	if roleID == "" {
+		roleID, _ = parseRoleSharingID(d.Id())
+	}
+
+	var bundle struct {
+		Total int                    `json:"total"`
+		Entry []iamRoleSharingPolicy `json:"entry"`
+	}
+	resp, err := iamRequest(client, http.MethodGet, roleSharingPolicyPath(roleID, ""), roleSharingAPIVersion, nil, &bundle)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			d.SetId("")
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+
+	// Only track the targets we manage, unless this is an import
+	managed := expandStringList(d.Get("target_organization_ids").(*schema.Set).List())
+	var targets []string
+	policies := map[string]string{}
+	purposes := map[string]string{}
+	for _, policy := range bundle.Entry {
+		if len(managed) > 0 && !containsString(managed, policy.TargetOrganizationID) {
+			continue
+		}
+		targets = append(targets, policy.TargetOrganizationID)
+		policies[policy.TargetOrganizationID] = policy.SharingPolicy
+		purposes[policy.TargetOrganizationID] = policy.Purpose
+		_ = d.Set("source_organization_id", policy.SourceOrganizationID)
+		_ = d.Set("role_name", policy.RoleName)
+	}
+	if len(targets) == 0 { // All shares were revoked outside of Terraform
+		d.SetId("")
+		return diags
+	}
+	sort.Strings(targets)
+	// The targets should share one policy. When they differ a warning is reported and the
+	// attribute is cleared, so a configured value is applied to all targets again
+	for _, attribute := range []string{"sharing_policy", "purpose"} {
+		values := policies
+		if attribute == "purpose" {
+			values = purposes
+		}
+		value := values[targets[0]]
+		conflict := false
+		var found []string
+		for _, target := range targets {
+			found = append(found, fmt.Sprintf("%s=%q", target, values[target]))
+			conflict = conflict || values[target] != value
+		}
+		if conflict {
+			value = ""
+			diags = append(diags, diag.Diagnostic{
+				Severity: diag.Warning,
+				Summary:  fmt.Sprintf("conflicting %s for role %s", attribute, roleID),
+				Detail:   fmt.Sprintf("the target organizations have different values: %s", strings.Join(found, ", ")),
+			})
+		}
+		_ = d.Set(attribute, value)
+	}
+	_ = d.Set("role_id", roleID)
+	_ = d.Set("target_organization_ids", targets)
+	return diags
+}
+
+func resourceIAMRoleSharingUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	roleID := d.Get("role_id").(string)
+
+	o, n := d.GetChange("target_organization_ids")
+	oldTargets := expandStringList(o.(*schema.Set).List())
+	newTargets := expandStringList(n.(*schema.Set).List())
+
+	toApply := difference(newTargets, oldTargets)
+	if d.HasChanges("sharing_policy", "purpose") {
+		toApply = newTargets
+	}
+	for _, target := range toApply {
+		if err := applyRoleSharingPolicy(client, roleID, target, d); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	for _, target := range difference(oldTargets, newTargets) {
+		if err := removeRoleSharingPolicy(client, roleID, target); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	return resourceIAMRoleSharingRead(ctx, d, m)
+}
+
+func resourceIAMRoleSharingDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	roleID := d.Get("role_id").(string)
+	for _, target := range expandStringList(d.Get("target_organization_ids").(*schema.Set).List()) {
+		if err := removeRoleSharingPolicy(client, roleID, target); err != nil {
+			return diag.FromErr(err)
+		}
+	}
+	d.SetId("")
+	return diags
+}
@@ -0,0 +1,128 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+// roleSharingStub stores the sharing policies of roles by target organization
+type roleSharingStub struct {
+	mu       sync.Mutex
+	policies map[string]map[string]iamRoleSharingPolicy
+}
+
+func (s *roleSharingStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	roleID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/authorize/identity/Role/"), "/$sharing-policy")
+	if s.policies[roleID] == nil {
+		s.policies[roleID] = make(map[string]iamRoleSharingPolicy)
+	}
+	switch r.Method {
+	case http.MethodPost:
+		var policy iamRoleSharingPolicy
+		_ = json.NewDecoder(r.Body).Decode(&policy)
+		policy.SourceOrganizationID = "org-source"
+		policy.RoleName = "AUDITOR"
+		s.policies[roleID][policy.TargetOrganizationID] = policy
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(policy)
+	case http.MethodGet:
+		var targets []string
+		for target := range s.policies[roleID] {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+		bundle := struct {
+			Total int                    `json:"total"`
+			Entry []iamRoleSharingPolicy `json:"entry"`
+		}{Total: len(targets)}
+		for _, target := range targets {
+			bundle.Entry = append(bundle.Entry, s.policies[roleID][target])
+		}
+		_ = json.NewEncoder(w).Encode(bundle)
+	case http.MethodDelete:
+		delete(s.policies[roleID], r.URL.Query().Get("targetOrganizationId"))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestResourceIAMRoleSharing(t *testing.T) {
+	stub := &roleSharingStub{policies: make(map[string]map[string]iamRoleSharingPolicy)}
+	meta := newTestIAMConfig(t, stub)
+	r := resourceIAMRoleSharing()
+	ctx := context.Background()
+
+	create := func(policy string, targets ...interface{}) *schema.ResourceData {
+		d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+			"role_id":                 "role-1",
+			"sharing_policy":          policy,
+			"target_organization_ids": targets,
+		})
+		diags := r.CreateContext(ctx, d, meta)
+		assert.False(t, diags.HasError(), "%v", diags)
+		return d
+	}
+
+	// Two sharings of the same role get their own ID and only track their own targets
+	a := create("Restricted", "org-b", "org-a")
+	b := create("AllowChildren", "org-c")
+	assert.Equal(t, "role-1/org-a,org-b", a.Id())
+	assert.Equal(t, "role-1/org-c", b.Id())
+
+	diags := r.ReadContext(ctx, a, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Empty(t, diags)
+	assert.Equal(t, "Restricted", a.Get("sharing_policy"))
+	assert.Equal(t, 2, a.Get("target_organization_ids.#"))
+	assert.Equal(t, "AUDITOR", a.Get("role_name"))
+
+	// A target changed outside of Terraform is reported and forces the policy to be applied again
+	stub.policies["role-1"]["org-b"] = iamRoleSharingPolicy{SharingPolicy: "Denied", TargetOrganizationID: "org-b"}
+	diags = r.ReadContext(ctx, a, meta)
+	if assert.Len(t, diags, 1) {
+		assert.Contains(t, diags[0].Summary, "conflicting sharing_policy")
+		assert.Contains(t, diags[0].Detail, `org-a="Restricted", org-b="Denied"`)
+	}
+	assert.Equal(t, "", a.Get("sharing_policy"))
+
+	// Deleting one sharing keeps the other
+	diags = r.DeleteContext(ctx, a, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	diags = r.ReadContext(ctx, b, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "role-1/org-c", b.Id())
+
+	// Import by role ID picks up all remaining targets
+	create("Restricted", "org-d")
+	d := r.TestResourceData()
+	d.SetId("role-1")
+	imported, err := r.Importer.StateContext(ctx, d, meta)
+	if assert.Nil(t, err) && assert.Len(t, imported, 1) {
+		assert.Equal(t, "role-1/org-c,org-d", imported[0].Id())
+		assert.Equal(t, "", imported[0].Get("sharing_policy"))
+	}
+
+	// Import of specific targets
+	d = r.TestResourceData()
+	d.SetId("role-1/org-d")
+	imported, err = r.Importer.StateContext(ctx, d, meta)
+	if assert.Nil(t, err) && assert.Len(t, imported, 1) {
+		assert.Equal(t, "role-1/org-d", imported[0].Id())
+		assert.Equal(t, "Restricted", imported[0].Get("sharing_policy"))
+	}
+
+	d = r.TestResourceData()
+	d.SetId("role-1/org-x")
+	_, err = r.Importer.StateContext(ctx, d, meta)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,86 @@
+package hsdp
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateRolePermissionsDiff(t *testing.T) {
+	var calls, failures int32
+	atomic.StoreInt32(&failures, 1)
+	meta := newTestIAMConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.Header().Set("Content-Type", "application/json")
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"responseCode":"503","responseMessage":"unavailable"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"total":2,"entry":[{"name":"USER.READ"},{"name":"USER.WRITE"}]}`))
+	}))
+	r := resourceIAMRole()
+	diff := func(permissions ...interface{}) error {
+		_, err := r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":                  "role",
+			"managing_organization": "org",
+			"permissions":           permissions,
+		}), meta)
+		return err
+	}
+
+	// An unavailable catalog does not block the plan and is not cached
+	assert.Nil(t, diff("USER.REED"))
+	assert.Nil(t, diff("USER.READ", "USER.WRITE"))
+	err := diff("USER.READ", "USER.REED")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "USER.REED (did you mean USER.READ")
+	}
+	// The catalog is cached after the first successful fetch
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+}
+
+func TestValidateRolePermissionsDiffTicketProtection(t *testing.T) {
+	meta := newTestIAMConfig(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"total":2,"entry":[{"name":"USER.READ"},{"name":"CLIENT.SCOPES"}]}`))
+	}))
+	r := resourceIAMRole()
+	state := &terraform.InstanceState{ID: "role", Attributes: map[string]string{
+		"id":                    "role",
+		"name":                  "ROLE",
+		"managing_organization": "org",
+		"ticket_protection":     "true",
+		"permissions.#":         "2",
+		"permissions.1":         "USER.READ",
+		"permissions.2":         "CLIENT.SCOPES",
+	}}
+	diff := func(ticketProtection bool, permissions ...interface{}) error {
+		_, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(map[string]interface{}{
+			"name":                  "ROLE",
+			"managing_organization": "org",
+			"ticket_protection":     ticketProtection,
+			"permissions":           permissions,
+		}), meta)
+		return err
+	}
+
+	assert.Nil(t, diff(true, "USER.READ", "CLIENT.SCOPES"))
+	err := diff(true, "USER.READ")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "ticket_protection")
+	}
+	assert.Nil(t, diff(false, "USER.READ"))
+}
+
+func TestSensitivePermissionWarnings(t *testing.T) {
+	assert.Empty(t, sensitivePermissionWarnings([]string{"USER.READ"}))
+	diags := sensitivePermissionWarnings([]string{"USER.READ", "ROLE.WRITE"})
+	if assert.Len(t, diags, 1) {
+		assert.Contains(t, diags[0].Summary, "ROLE.WRITE")
+	}
+}
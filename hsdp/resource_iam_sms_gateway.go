@@ -0,0 +1,249 @@
+package hsdp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+const (
+	smsGatewayAPIVersion = "1"
+	smsGatewayPath       = "authorize/scim/v2/Configurations/SMSGateway"
+	smsGatewaySchema     = "urn:ietf:params:scim:schemas:core:philips:hsdp:2.0:SMSGateway"
+)
+
+type iamValueReference struct {
+	Value string `json:"value"`
+}
+
+// iamSMSGateway describes the SMS gateway configuration of an IAM organization
+type iamSMSGateway struct {
+	Schemas      []string          `json:"schemas"`
+	ID           string            `json:"id,omitempty"`
+	Organization iamValueReference `json:"organization"`
+	ExternalID   string            `json:"externalId,omitempty"`
+	Provider     string            `json:"provider"`
+	Properties   struct {
+		SID        string `json:"sid"`
+		Endpoint   string `json:"endpoint"`
+		FromNumber string `json:"fromNumber"`
+	} `json:"properties"`
+	Credentials      *iamSMSGatewayCredentials `json:"credentials,omitempty"`
+	Active           bool                      `json:"active"`
+	ActivationExpiry int                       `json:"activationExpiry"`
+	Meta             *iam.Meta                 `json:"meta,omitempty"`
+}
+
+// iamSMSGatewayCredentials holds the gateway provider secrets. These are write-only
+type iamSMSGatewayCredentials struct {
+	Token string `json:"token"`
+}
+
+func resourceIAMSMSGateway() *schema.Resource {
+	return &schema.Resource{
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		CreateContext: resourceIAMSMSGatewayCreate,
+		ReadContext:   resourceIAMSMSGatewayRead,
+		UpdateContext: resourceIAMSMSGatewayUpdate,
+		DeleteContext: resourceIAMSMSGatewayDelete,
+
+		Schema: map[string]*schema.Schema{
+			"organization_id": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"gateway_provider": {
+				Type:         schema.TypeString,
+				Optional:     true,
+				Default:      "twilio",
+				ValidateFunc: validation.StringInSlice([]string{"twilio"}, false),
+			},
+			"external_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+			},
+			"properties": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"sid": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"endpoint": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+						"from_number": {
+							Type:     schema.TypeString,
+							Required: true,
+						},
+					},
+				},
+			},
+			"credentials": {
+				Type:     schema.TypeList,
+				Required: true,
+				MaxItems: 1,
+				Elem: &schema.Resource{
+					Schema: map[string]*schema.Schema{
+						"token": {
+							Type:      schema.TypeString,
+							Required:  true,
+							Sensitive: true,
+						},
+					},
+				},
+			},
+			"activation_expiry": {
+				Type:         schema.TypeInt,
+				Optional:     true,
+				Default:      15,
+				ValidateFunc: validation.IntBetween(1, 43200),
+			},
+			"active": {
+				Type:     schema.TypeBool,
+				Optional: true,
+				Default:  true,
+			},
+			"version": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func resourceDataToSMSGateway(d *schema.ResourceData) iamSMSGateway {
+	var gateway iamSMSGateway
+
+	gateway.Schemas = []string{smsGatewaySchema}
+	gateway.Organization.Value = d.Get("organization_id").(string)
+	gateway.ExternalID = d.Get("external_id").(string)
+	gateway.Provider = d.Get("gateway_provider").(string)
+	gateway.Active = d.Get("active").(bool)
+	gateway.ActivationExpiry = d.Get("activation_expiry").(int)
+	if v, ok := d.GetOk("properties"); ok {
+		for _, vi := range v.([]interface{}) {
+			mVi := vi.(map[string]interface{})
+			gateway.Properties.SID = mVi["sid"].(string)
+			gateway.Properties.Endpoint = mVi["endpoint"].(string)
+			gateway.Properties.FromNumber = mVi["from_number"].(string)
+		}
+	}
+	if v, ok := d.GetOk("credentials"); ok {
+		for _, vi := range v.([]interface{}) {
+			mVi := vi.(map[string]interface{})
+			gateway.Credentials = &iamSMSGatewayCredentials{
+				Token: mVi["token"].(string),
+			}
+		}
+	}
+	return gateway
+}
+
+func resourceIAMSMSGatewayCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	gateway := resourceDataToSMSGateway(d)
+
+	var created iamSMSGateway
+	err = tryIAMCall(func() (*iam.Response, error) {
+		return iamRequest(client, http.MethodPost, smsGatewayPath, smsGatewayAPIVersion, gateway, &created)
+	}, http.StatusInternalServerError)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if created.ID == "" {
+		return diag.FromErr(fmt.Errorf("create SMS gateway: %w", ErrInvalidResponse))
+	}
+	d.SetId(created.ID)
+	return resourceIAMSMSGatewayRead(ctx, d, m)
+}
+
+func resourceIAMSMSGatewayRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var gateway iamSMSGateway
+	resp, err := iamRequest(client, http.MethodGet, smsGatewayPath+"/"+d.Id(), smsGatewayAPIVersion, nil, &gateway)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			d.SetId("")
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+	_ = d.Set("organization_id", gateway.Organization.Value)
+	_ = d.Set("external_id", gateway.ExternalID)
+	_ = d.Set("gateway_provider", gateway.Provider)
+	_ = d.Set("active", gateway.Active)
+	_ = d.Set("activation_expiry", gateway.ActivationExpiry)
+	_ = d.Set("properties", []interface{}{map[string]interface{}{
+		"sid":         gateway.Properties.SID,
+		"endpoint":    gateway.Properties.Endpoint,
+		"from_number": gateway.Properties.FromNumber,
+	}})
+	// Credentials are not returned in the read call
+	if gateway.Meta != nil {
+		_ = d.Set("version", gateway.Meta.Version)
+	}
+	return diags
+}
+
+func resourceIAMSMSGatewayUpdate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	gateway := resourceDataToSMSGateway(d)
+	gateway.ID = d.Id()
+
+	_, err = iamRequest(client, http.MethodPut, smsGatewayPath+"/"+d.Id(), smsGatewayAPIVersion, gateway, nil,
+		withIfMatch(d.Get("version").(string)))
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	return resourceIAMSMSGatewayRead(ctx, d, m)
+}
+
+func resourceIAMSMSGatewayDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	resp, err := iamRequest(client, http.MethodDelete, smsGatewayPath+"/"+d.Id(), smsGatewayAPIVersion, nil, nil,
+		withIfMatch(d.Get("version").(string)))
+	if err != nil && !(resp != nil && resp.StatusCode == http.StatusNotFound) {
+		return diag.FromErr(err)
+	}
+	d.SetId("")
+	return diags
+}
@@ -0,0 +1,77 @@
+package hsdp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourceIAMSMSGatewayCRUD(t *testing.T) {
+	stub := &scimStub{}
+	meta := newTestIAMConfig(t, stub)
+	r := resourceIAMSMSGateway()
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"organization_id": "org",
+		"properties": []interface{}{map[string]interface{}{
+			"sid":         "sid",
+			"endpoint":    "https://api.twilio.com",
+			"from_number": "+31600000000",
+		}},
+		"credentials": []interface{}{map[string]interface{}{"token": "secret"}},
+	})
+	diags := r.CreateContext(context.Background(), d, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, "id-1", d.Id())
+	assert.Equal(t, "W/\"1\"", d.Get("version"))
+	assert.Equal(t, "twilio", d.Get("gateway_provider"))
+	assert.Equal(t, "+31600000000", d.Get("properties.0.from_number"))
+	assert.Equal(t, 15, d.Get("activation_expiry"))
+
+	_ = d.Set("activation_expiry", 30)
+	diags = r.UpdateContext(context.Background(), d, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "W/\"2\"", d.Get("version"))
+	assert.Equal(t, 30, d.Get("activation_expiry"))
+
+	diags = r.DeleteContext(context.Background(), d, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Empty(t, stub.resources)
+
+	// Gone outside Terraform
+	d.SetId("id-1")
+	diags = r.ReadContext(context.Background(), d, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Equal(t, "", d.Id())
+}
+
+func TestResourceIAMSMSTemplateCRUD(t *testing.T) {
+	stub := &scimStub{}
+	meta := newTestIAMConfig(t, stub)
+	r := resourceIAMSMSTemplate()
+
+	d := schema.TestResourceDataRaw(t, r.Schema, map[string]interface{}{
+		"managing_organization": "org",
+		"type":                  "MFA_OTP",
+		"message":               "Your code is {{template.otp}}",
+		"locale":                "en-US",
+	})
+	diags := r.CreateContext(context.Background(), d, meta)
+	if !assert.False(t, diags.HasError(), "%v", diags) {
+		return
+	}
+	assert.Equal(t, "id-1", d.Id())
+	assert.Equal(t, "Your code is {{template.otp}}", d.Get("message"))
+	assert.Equal(t, "WW91ciBjb2RlIGlzIHt7dGVtcGxhdGUub3RwfX0=", d.Get("message_base64"))
+	assert.Equal(t, "en-US", d.Get("locale"))
+	assert.Equal(t, "W/\"1\"", d.Get("version"))
+
+	diags = r.DeleteContext(context.Background(), d, meta)
+	assert.False(t, diags.HasError(), "%v", diags)
+	assert.Empty(t, stub.resources)
+	assert.Equal(t, "", d.Id())
+}
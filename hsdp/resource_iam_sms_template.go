@@ -0,0 +1,196 @@
+package hsdp
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/diag"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/validation"
+	"github.com/philips-software/go-hsdp-api/iam"
+)
+
+const (
+	smsTemplateAPIVersion = "1"
+	smsTemplatePath       = "authorize/scim/v2/Configurations/SMSTemplate"
+	smsTemplateSchema     = "urn:ietf:params:scim:schemas:core:philips:hsdp:2.0:SMSTemplate"
+)
+
+// smsTemplatePlaceholders lists the placeholders IAM substitutes per SMS template type
+var smsTemplatePlaceholders = map[string][]string{
+	"PHONE_VERIFICATION": {"template.otp", "template.expiryPeriod"},
+	"MFA_OTP":            {"template.otp", "template.expiryPeriod"},
+}
+
+// iamSMSTemplate describes an SMS template of an IAM organization
+type iamSMSTemplate struct {
+	Schemas      []string          `json:"schemas"`
+	ID           string            `json:"id,omitempty"`
+	Type         string            `json:"type"`
+	Organization iamValueReference `json:"organization"`
+	ExternalID   string            `json:"externalId,omitempty"`
+	Locale       string            `json:"locale,omitempty"`
+	Message      string            `json:"message"`
+	Meta         *iam.Meta         `json:"meta,omitempty"`
+}
+
+func resourceIAMSMSTemplate() *schema.Resource {
+	return &schema.Resource{
+		Importer: &schema.ResourceImporter{
+			StateContext: schema.ImportStatePassthroughContext,
+		},
+
+		CreateContext: resourceIAMSMSTemplateCreate,
+		ReadContext:   resourceIAMSMSTemplateRead,
+		DeleteContext: resourceIAMSMSTemplateDelete,
+		CustomizeDiff: validateSMSTemplateDiff,
+
+		Schema: map[string]*schema.Schema{
+			"managing_organization": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"type": {
+				Type:         schema.TypeString,
+				Required:     true,
+				ForceNew:     true,
+				ValidateFunc: validation.StringInSlice([]string{"PHONE_VERIFICATION", "MFA_OTP"}, false),
+			},
+			"message": {
+				Type:     schema.TypeString,
+				Required: true,
+				ForceNew: true,
+			},
+			"locale": {
+				Type:             schema.TypeString,
+				Optional:         true,
+				ForceNew:         true,
+				DiffSuppressFunc: suppressDefault,
+			},
+			"external_id": {
+				Type:     schema.TypeString,
+				Optional: true,
+				ForceNew: true,
+			},
+			"message_base64": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+			"version": {
+				Type:     schema.TypeString,
+				Computed: true,
+			},
+		},
+	}
+}
+
+func validateSMSTemplateDiff(_ context.Context, d *schema.ResourceDiff, _ interface{}) error {
+	if !d.NewValueKnown("message") || !d.NewValueKnown("type") {
+		return nil
+	}
+	templateType := d.Get("type").(string)
+	message := d.Get("message").(string)
+	allowed, ok := smsTemplatePlaceholders[templateType]
+	if !ok {
+		return nil // Caught by the type validation
+	}
+	if err := validatePlaceholders(message, allowed); err != nil {
+		return fmt.Errorf("message of %s template: %w", templateType, err)
+	}
+	found := findPlaceholders(message)
+	for _, p := range found {
+		if p == "template.otp" {
+			return nil
+		}
+	}
+	return fmt.Errorf("message of %s template must contain the {{template.otp}} placeholder", templateType)
+}
+
+func resourceIAMSMSTemplateCreate(ctx context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	var template iamSMSTemplate
+
+	template.Schemas = []string{smsTemplateSchema}
+	template.Type = d.Get("type").(string)
+	template.Organization.Value = d.Get("managing_organization").(string)
+	template.ExternalID = d.Get("external_id").(string)
+	template.Locale = d.Get("locale").(string)
+	template.Message = base64.StdEncoding.EncodeToString([]byte(d.Get("message").(string)))
+
+	var createdTemplate iamSMSTemplate
+	err = tryIAMCall(func() (*iam.Response, error) {
+		return iamRequest(client, http.MethodPost, smsTemplatePath, smsTemplateAPIVersion, template, &createdTemplate)
+	}, http.StatusInternalServerError)
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	if createdTemplate.ID == "" {
+		return diag.FromErr(fmt.Errorf("create SMS template: %w", ErrInvalidResponse))
+	}
+	_ = d.Set("message_base64", template.Message)
+	d.SetId(createdTemplate.ID)
+	return resourceIAMSMSTemplateRead(ctx, d, m)
+}
+
+func resourceIAMSMSTemplateRead(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+
+	var template iamSMSTemplate
+	resp, err := iamRequest(client, http.MethodGet, smsTemplatePath+"/"+d.Id(), smsTemplateAPIVersion, nil, &template)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			d.SetId("")
+			return diags
+		}
+		return diag.FromErr(err)
+	}
+	_ = d.Set("type", template.Type)
+	_ = d.Set("managing_organization", template.Organization.Value)
+	_ = d.Set("external_id", template.ExternalID)
+	if template.Locale != "default" {
+		_ = d.Set("locale", template.Locale)
+	}
+	if template.Message != "" {
+		if message, err := base64.StdEncoding.DecodeString(template.Message); err == nil {
+			_ = d.Set("message", string(message))
+			_ = d.Set("message_base64", template.Message)
+		}
+	}
+	if template.Meta != nil {
+		_ = d.Set("version", template.Meta.Version)
+	}
+	return diags
+}
+
+func resourceIAMSMSTemplateDelete(_ context.Context, d *schema.ResourceData, m interface{}) diag.Diagnostics {
+	config := m.(*Config)
+
+	var diags diag.Diagnostics
+
+	client, err := config.IAMClient()
+	if err != nil {
+		return diag.FromErr(err)
+	}
+	resp, err := iamRequest(client, http.MethodDelete, smsTemplatePath+"/"+d.Id(), smsTemplateAPIVersion, nil, nil,
+		withIfMatch(d.Get("version").(string)))
+	if err != nil && !(resp != nil && resp.StatusCode == http.StatusNotFound) {
+		return diag.FromErr(err)
+	}
+	d.SetId("")
+	return diags
+}
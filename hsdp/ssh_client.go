@@ -0,0 +1,351 @@
+package hsdp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+const sshDefaultTimeout = 60 * time.Second
+
+// sshBastion holds the connection details of the bastion host
+type sshBastion struct {
+	User        string
+	Server      string
+	Port        string
+	Key         string
+	Certificate string
+	Agent       bool
+	Fingerprint string
+}
+
+// sshConfig holds the connection details of a host which is reached through an
+// optional bastion. Key and Certificate are used together with the keys held by ssh-agent
+// when Agent is set.
+// Run, Stream and WriteFile share a pooled connection, see sshPool
+type sshConfig struct {
+	User        string
+	Server      string
+	Port        string
+	Key         string
+	Certificate string
+	Agent       bool
+	Fingerprint string
+	Proxy       func(*http.Request) (*url.URL, error)
+	Bastion     sshBastion
+}
+
+// parseCertificate parses an OpenSSH certificate in authorized_keys format
+func parseCertificate(certificate string) (*ssh.Certificate, error) {
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(certificate))
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("parsing certificate: %s is not an OpenSSH certificate", key.Type())
+	}
+	return cert, nil
+}
+
+// certSigners pairs the certificate with the signers holding its private key
+func certSigners(cert *ssh.Certificate, signers []ssh.Signer) ([]ssh.Signer, error) {
+	var paired []ssh.Signer
+	for _, signer := range signers {
+		if !bytes.Equal(signer.PublicKey().Marshal(), cert.Key.Marshal()) {
+			continue
+		}
+		certSigner, err := ssh.NewCertSigner(cert, signer)
+		if err != nil {
+			return nil, err
+		}
+		paired = append(paired, certSigner)
+	}
+	if len(paired) == 0 {
+		return nil, fmt.Errorf("certificate does not match the private key or any key in ssh-agent")
+	}
+	return paired, nil
+}
+
+// sshAuthMethods returns the private key and, when useAgent is set, the keys and certificates
+// held by ssh-agent. An inline certificate is paired with the private key, or else with the
+// matching agent key
+func sshAuthMethods(privateKey, certificate string, useAgent bool) ([]ssh.AuthMethod, func(), error) {
+	var signers []ssh.Signer
+	closer := func() {}
+	if privateKey != "" {
+		signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+		if err != nil {
+			return nil, closer, fmt.Errorf("parsing private key: %w", err)
+		}
+		signers = append(signers, signer)
+	}
+	if useAgent {
+		if conn, err := net.Dial("unix", os.Getenv("SSH_AUTH_SOCK")); err == nil {
+			closer = func() { _ = conn.Close() }
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+		}
+	}
+	if certificate != "" {
+		cert, err := parseCertificate(certificate)
+		if err != nil {
+			closer()
+			return nil, func() {}, err
+		}
+		paired, err := certSigners(cert, signers)
+		if err != nil {
+			closer()
+			return nil, func() {}, err
+		}
+		// Offer the certificate first, servers limit the number of attempts
+		signers = append(paired, signers...)
+	}
+	return []ssh.AuthMethod{ssh.PublicKeys(signers...)}, closer, nil
+}
+
+// dial connects to the host, through the bastion when one is set. Host keys are checked
+// against the configured fingerprints and the knownHosts callback, when given. The presented
+// fingerprints are recorded in hostFingerprint and bastionFingerprint, also when the handshake fails.
+// Only scans, which pass hostFingerprint, may connect to hosts whose keys are not pinned
+func (c *sshConfig) dial(knownHosts ssh.HostKeyCallback, hostFingerprint, bastionFingerprint *string) (*ssh.Client, func(), error) {
+	if hostFingerprint == nil {
+		if err := c.checkPinned(); err != nil {
+			return nil, nil, err
+		}
+	}
+	var closers []func()
+	closeAll := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+	if hostFingerprint == nil {
+		hostFingerprint = new(string)
+	}
+	if bastionFingerprint == nil {
+		bastionFingerprint = new(string)
+	}
+	targetAddr := net.JoinHostPort(c.Server, c.Port)
+	targetAuths, closeTarget, err := sshAuthMethods(c.Key, c.Certificate, c.Agent)
+	if err != nil {
+		return nil, nil, fmt.Errorf("host %s: %w", c.Server, err)
+	}
+	closers = append(closers, closeTarget)
+
+	var conn net.Conn
+	if c.Bastion.Server != "" {
+		bastionAuths, closeBastion, err := sshAuthMethods(c.Bastion.Key, c.Bastion.Certificate, c.Bastion.Agent)
+		if err != nil {
+			closeAll()
+			return nil, nil, fmt.Errorf("bastion %s: %w", c.Bastion.Server, err)
+		}
+		closers = append(closers, closeBastion)
+		bastionAddr := net.JoinHostPort(c.Bastion.Server, c.Bastion.Port)
+		bastionConn, err := dialThroughProxy(context.Background(), c.Proxy, bastionAddr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		bc, chans, reqs, err := ssh.NewClientConn(bastionConn, bastionAddr, &ssh.ClientConfig{
+			User:            c.Bastion.User,
+			Auth:            bastionAuths,
+			HostKeyCallback: recordingHostKeyCallback(c.Bastion.Fingerprint, knownHosts, bastionFingerprint),
+			Timeout:         sshDialTimeout,
+		})
+		if err != nil {
+			_ = bastionConn.Close()
+			closeAll()
+			return nil, nil, fmt.Errorf("bastion %s: %w", c.Bastion.Server, err)
+		}
+		bastionClient := ssh.NewClient(bc, chans, reqs)
+		closers = append(closers, func() { _ = bastionClient.Close() })
+		conn, err = bastionClient.Dial("tcp", targetAddr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+	} else {
+		conn, err = dialThroughProxy(context.Background(), c.Proxy, targetAddr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+	}
+	tc, chans, reqs, err := ssh.NewClientConn(conn, targetAddr, &ssh.ClientConfig{
+		User:            c.User,
+		Auth:            targetAuths,
+		HostKeyCallback: recordingHostKeyCallback(c.Fingerprint, knownHosts, hostFingerprint),
+		Timeout:         sshDialTimeout,
+	})
+	if err != nil {
+		_ = conn.Close()
+		closeAll()
+		return nil, nil, fmt.Errorf("host %s: %w", c.Server, err)
+	}
+	client := ssh.NewClient(tc, chans, reqs)
+	closers = append(closers, func() { _ = client.Close() })
+	return client, closeAll, nil
+}
+
+// Run executes the command on the host. done is false when the command did not finish
+// within the timeout, which defaults to one minute
+func (c *sshConfig) Run(command string, timeout ...time.Duration) (stdout string, stderr string, done bool, err error) {
+	executeTimeout := sshDefaultTimeout
+	if len(timeout) > 0 {
+		executeTimeout = timeout[0]
+	}
+	var outBuf, errBuf bytes.Buffer
+	done, err = c.Stream(command, executeTimeout, &outBuf, &errBuf)
+	return outBuf.String(), errBuf.String(), done, err
+}
+
+// Stream executes the command on the host and writes its output to stdout and stderr as it
+// arrives. done is false when the command did not finish within the timeout
+func (c *sshConfig) Stream(command string, timeout time.Duration, stdout, stderr io.Writer) (bool, error) {
+	session, err := c.newSession()
+	if err != nil {
+		return false, err
+	}
+	defer session.Close()
+
+	session.Stdout = stdout
+	session.Stderr = stderr
+	result := make(chan error, 1)
+	go func() {
+		result <- session.Run(command)
+	}()
+	select {
+	case err = <-result:
+		return true, err
+	case <-time.After(timeout):
+		// Only the session is torn down, the pooled connection stays usable
+		_ = session.Signal(ssh.SIGKILL)
+		_ = session.Close()
+		<-result
+		return false, fmt.Errorf("command timed out after %v", timeout)
+	}
+}
+
+// WriteFile copies size bytes from reader to dest on the host using the scp protocol
+func (c *sshConfig) WriteFile(reader io.Reader, size int64, dest string) error {
+	session, err := c.newSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	w, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	copyErr := make(chan error, 1)
+	go func() {
+		defer w.Close()
+		if _, err := fmt.Fprintln(w, "C0644", size, filepath.Base(dest)); err != nil {
+			copyErr <- err
+			return
+		}
+		if size > 0 {
+			if _, err := io.Copy(w, reader); err != nil {
+				copyErr <- err
+				return
+			}
+		}
+		_, err := fmt.Fprint(w, "\x00")
+		copyErr <- err
+	}()
+	if err := session.Run("scp -tr " + shellQuote(dest)); err != nil {
+		return err
+	}
+	return <-copyErr
+}
+
+// DialTCP connects to a port of the host from the bastion, or directly when there is no bastion.
+// The bastion connection is pooled like the host connections. When ctx is done before the
+// bastion opened the channel, the channel is closed as soon as it arrives
+func (c *sshConfig) DialTCP(ctx context.Context, port int) (net.Conn, error) {
+	addr := net.JoinHostPort(c.Server, strconv.Itoa(port))
+	if c.Bastion.Server == "" {
+		return dialThroughProxy(ctx, c.Proxy, addr)
+	}
+	bastion := &sshConfig{
+		User:        c.Bastion.User,
+		Server:      c.Bastion.Server,
+		Port:        c.Bastion.Port,
+		Key:         c.Bastion.Key,
+		Certificate: c.Bastion.Certificate,
+		Agent:       c.Bastion.Agent,
+		Fingerprint: c.Bastion.Fingerprint,
+		Proxy:       c.Proxy,
+	}
+	client, err := connectionPool.get(bastion)
+	if err != nil {
+		return nil, err
+	}
+	type dialResult struct {
+		conn net.Conn
+		err  error
+	}
+	result := make(chan dialResult, 1)
+	go func() {
+		conn, err := client.Dial("tcp", addr)
+		result <- dialResult{conn, err}
+	}()
+	select {
+	case r := <-result:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-result; r.conn != nil {
+				_ = r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// expandSSHConfig returns the connection details of server from the user, private_key and
+// certificate arguments. The bastion uses the bastion_* arguments, which default to those of the host
+func expandSSHConfig(d *schema.ResourceData, server, bastionHost string) *sshConfig {
+	cfg := &sshConfig{
+		User:        d.Get("user").(string),
+		Server:      server,
+		Port:        "22",
+		Key:         d.Get("private_key").(string),
+		Certificate: d.Get("certificate").(string),
+		Agent:       d.Get("agent").(bool),
+		Proxy:       http.ProxyFromEnvironment,
+		Bastion: sshBastion{
+			User:        d.Get("bastion_user").(string),
+			Server:      bastionHost,
+			Port:        "22",
+			Key:         d.Get("bastion_private_key").(string),
+			Certificate: d.Get("bastion_certificate").(string),
+			Agent:       d.Get("agent").(bool),
+		},
+	}
+	if cfg.Bastion.User == "" {
+		cfg.Bastion.User = cfg.User
+	}
+	if cfg.Bastion.Key == "" {
+		if cfg.Bastion.Certificate == "" {
+			cfg.Bastion.Certificate = cfg.Certificate
+		}
+		cfg.Bastion.Key = cfg.Key
+	}
+	return cfg
+}
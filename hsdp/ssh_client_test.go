@@ -0,0 +1,122 @@
+package hsdp
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestCertificateAuth(t *testing.T) {
+	_, caKey, _ := ed25519.GenerateKey(rand.Reader)
+	ca, _ := ssh.NewSignerFromKey(caKey)
+	userKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	user, _ := ssh.NewSignerFromKey(userKey)
+
+	cert := &ssh.Certificate{
+		Key:             user.PublicKey(),
+		CertType:        ssh.UserCert,
+		KeyId:           "test",
+		ValidPrincipals: []string{"core"},
+		ValidBefore:     ssh.CertTimeInfinity,
+	}
+	if err := cert.SignCert(rand.Reader, ca); err != nil {
+		t.Fatal(err)
+	}
+	checker := &ssh.CertChecker{
+		IsUserAuthority: func(auth ssh.PublicKey) bool {
+			return string(auth.Marshal()) == string(ca.PublicKey().Marshal())
+		},
+	}
+	addr, _, stop := startTestSSHServer(t, &ssh.ServerConfig{PublicKeyCallback: checker.Authenticate})
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+
+	der, err := x509.MarshalECPrivateKey(userKey)
+	if !assert.Nil(t, err) {
+		return
+	}
+	cfg := &sshConfig{
+		User:        "core",
+		Server:      host,
+		Port:        port,
+		Key:         string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})),
+		Certificate: string(ssh.MarshalAuthorizedKey(cert)),
+	}
+	_, _, err = scanHostKeys(cfg, "", "", "")
+	assert.Nil(t, err)
+
+	// The key alone is not trusted by the server
+	cfg.Certificate = ""
+	_, _, err = scanHostKeys(cfg, "", "", "")
+	assert.NotNil(t, err)
+
+	// A certificate for another key is refused before connecting
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := ssh.NewSignerFromKey(otherKey)
+	cert.Key = other.PublicKey()
+	_ = cert.SignCert(rand.Reader, ca)
+	cfg.Certificate = string(ssh.MarshalAuthorizedKey(cert))
+	_, _, err = scanHostKeys(cfg, "", "", "")
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "certificate does not match")
+	}
+}
+
+func TestAgentKeysOnlyWhenEnabled(t *testing.T) {
+	_, userKey, _ := ed25519.GenerateKey(rand.Reader)
+	user, _ := ssh.NewSignerFromKey(userKey)
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: userKey}); err != nil {
+		t.Fatal(err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() { _ = agent.ServeAgent(keyring, conn) }()
+		}
+	}()
+	previous := os.Getenv("SSH_AUTH_SOCK")
+	_ = os.Setenv("SSH_AUTH_SOCK", socket)
+	defer os.Setenv("SSH_AUTH_SOCK", previous)
+
+	addr, fingerprint, stop := startTestSSHServer(t, &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), user.PublicKey().Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key")
+		},
+	})
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+
+	cfg := &sshConfig{User: "core", Server: host, Port: port, Fingerprint: fingerprint}
+	_, _, _, err = cfg.Run("echo")
+	assert.NotNil(t, err)
+
+	cfg.Agent = true
+	_, _, _, err = cfg.Run("echo")
+	assert.Nil(t, err)
+}
@@ -0,0 +1,167 @@
+package hsdp
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/hashicorp/terraform-plugin-sdk/v2/helper/schema"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	hostKeyField        = "host_key"
+	bastionHostKeyField = "bastion_host_key"
+	knownHostsFileField = "known_hosts_file"
+	sshDialTimeout      = 30 * time.Second
+
+	// hostKeyVerificationFailed marks errors which should not be retried. The ssh
+	// package flattens errors from the host key callback, so this is matched as text
+	hostKeyVerificationFailed = "host key verification failed"
+)
+
+func isHostKeyError(err error) bool {
+	return err != nil && strings.Contains(err.Error(), hostKeyVerificationFailed)
+}
+
+// recordingHostKeyCallback records the fingerprint of the presented key and checks it
+// against the expected fingerprint and known_hosts file, when given
+func recordingHostKeyCallback(expected string, knownHosts ssh.HostKeyCallback, captured *string) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		fingerprint := ssh.FingerprintSHA256(key)
+		*captured = fingerprint
+		if knownHosts != nil {
+			if err := knownHosts(hostname, remote, key); err != nil {
+				return fmt.Errorf("%s: known_hosts of %s: %v", hostKeyVerificationFailed, hostname, err)
+			}
+		}
+		if expected != "" && expected != fingerprint {
+			return fmt.Errorf("%s: %s presented %s, expected %s", hostKeyVerificationFailed, hostname, fingerprint, expected)
+		}
+		return nil
+	}
+}
+
+// dialThroughProxy opens a TCP connection to addr, using an HTTP CONNECT proxy when one is
+// configured. Dialing and the CONNECT handshake are aborted when ctx is done
+func dialThroughProxy(ctx context.Context, proxy func(*http.Request) (*url.URL, error), addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: sshDialTimeout}
+	if proxy != nil {
+		req, _ := http.NewRequest("CONNECT", "https://"+addr, nil)
+		proxyURL, err := proxy(req)
+		if err == nil && proxyURL != nil {
+			conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+			if err != nil {
+				return nil, fmt.Errorf("connecting to proxy: %w", err)
+			}
+			if deadline, ok := ctx.Deadline(); ok {
+				_ = conn.SetDeadline(deadline)
+			}
+			connect := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+			if proxyURL.User != nil {
+				password, _ := proxyURL.User.Password()
+				credentials := base64.StdEncoding.EncodeToString([]byte(proxyURL.User.Username() + ":" + password))
+				connect += "Proxy-Authorization: Basic " + credentials + "\r\n"
+			}
+			if _, err := conn.Write([]byte(connect + "\r\n")); err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			resp, err := http.ReadResponse(bufio.NewReader(conn), req)
+			if err != nil {
+				_ = conn.Close()
+				return nil, err
+			}
+			if resp.StatusCode != http.StatusOK {
+				_ = conn.Close()
+				return nil, fmt.Errorf("proxy CONNECT to %s: %s", addr, resp.Status)
+			}
+			_ = conn.SetDeadline(time.Time{})
+			return conn, nil
+		}
+	}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// scanHostKeys connects to the bastion and target host and returns their host key
+// fingerprints. Keys which do not match the expected fingerprint or the known_hosts
+// file fail the scan
+func scanHostKeys(cfg *sshConfig, expectedHost, expectedBastion, knownHostsFile string) (string, string, error) {
+	var knownHosts ssh.HostKeyCallback
+	if knownHostsFile != "" {
+		var err error
+		knownHosts, err = knownhosts.New(knownHostsFile)
+		if err != nil {
+			return "", "", fmt.Errorf("reading %s: %w", knownHostsFile, err)
+		}
+	}
+	scan := *cfg
+	scan.Fingerprint = expectedHost
+	scan.Bastion.Fingerprint = expectedBastion
+	var hostFingerprint, bastionFingerprint string
+	_, closeAll, err := scan.dial(knownHosts, &hostFingerprint, &bastionFingerprint)
+	if err != nil {
+		return hostFingerprint, bastionFingerprint, err
+	}
+	closeAll()
+	return hostFingerprint, bastionFingerprint, nil
+}
+
+// pinHostKeys makes sure the SSH client strictly verifies the host keys. Keys which are not
+// known yet are captured on first connect and stored in the state
+func pinHostKeys(cfg *sshConfig, d *schema.ResourceData) error {
+	hostKey := d.Get(hostKeyField).(string)
+	bastionKey := d.Get(bastionHostKeyField).(string)
+	knownHostsFile := d.Get(knownHostsFileField).(string)
+
+	needsBastion := cfg.Bastion.Server != "" && bastionKey == ""
+	if hostKey == "" || needsBastion || knownHostsFile != "" {
+		operation := func() error {
+			hostFingerprint, bastionFingerprint, err := scanHostKeys(cfg, hostKey, bastionKey, knownHostsFile)
+			if err != nil {
+				if isHostKeyError(err) {
+					return backoff.Permanent(err)
+				}
+				return err
+			}
+			hostKey, bastionKey = hostFingerprint, bastionFingerprint
+			return nil
+		}
+		// A freshly booted host might not accept connections yet
+		if err := backoff.Retry(operation, backoff.WithMaxRetries(backoff.NewExponentialBackOff(), 10)); err != nil {
+			return fmt.Errorf("host key verification: %w", err)
+		}
+		_ = d.Set(hostKeyField, hostKey)
+		_ = d.Set(bastionHostKeyField, bastionKey)
+	}
+	cfg.Fingerprint = hostKey
+	cfg.Bastion.Fingerprint = bastionKey
+	return nil
+}
+
+// applyHostKeys configures the SSH client with the pinned keys from the state, without connecting.
+// It fails when a key was never pinned, as the host can then not be verified
+func applyHostKeys(cfg *sshConfig, d *schema.ResourceData) error {
+	cfg.Fingerprint = d.Get(hostKeyField).(string)
+	cfg.Bastion.Fingerprint = d.Get(bastionHostKeyField).(string)
+	return cfg.checkPinned()
+}
+
+// checkPinned returns an error when the host or bastion key is not pinned
+func (c *sshConfig) checkPinned() error {
+	if c.Fingerprint == "" {
+		return fmt.Errorf("%s: the host key of %s is not pinned yet", hostKeyVerificationFailed, c.Server)
+	}
+	if c.Bastion.Server != "" && c.Bastion.Fingerprint == "" {
+		return fmt.Errorf("%s: the host key of bastion %s is not pinned yet", hostKeyVerificationFailed, c.Bastion.Server)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package hsdp
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+)
+
+// startTestSSHServer accepts SSH handshakes and returns its address and host key fingerprint.
+// Clients are not authenticated when config is nil
+func startTestSSHServer(t *testing.T, config *ssh.ServerConfig) (string, string, func()) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config == nil {
+		config = &ssh.ServerConfig{NoClientAuth: true}
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(conn, config)
+				if err != nil {
+					return
+				}
+				go ssh.DiscardRequests(reqs)
+				for c := range chans {
+					if c.ChannelType() != "session" {
+						_ = c.Reject(ssh.Prohibited, "test server")
+						continue
+					}
+					go serveTestSession(c)
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String(), ssh.FingerprintSHA256(signer.PublicKey()), func() { _ = listener.Close() }
+}
+
+// serveTestSession echoes exec commands to stdout and stderr. A command like "exit 3" exits with that status
+func serveTestSession(c ssh.NewChannel) {
+	channel, requests, err := c.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			_ = req.Reply(false, nil)
+			continue
+		}
+		_ = req.Reply(true, nil)
+		var payload struct{ Command string }
+		_ = ssh.Unmarshal(req.Payload, &payload)
+		status := 0
+		if _, err := fmt.Sscanf(payload.Command, "exit %d", &status); err != nil {
+			_, _ = fmt.Fprintf(channel, "out: %s\n", payload.Command)
+			_, _ = fmt.Fprintf(channel.Stderr(), "err: %s\n", payload.Command)
+		}
+		_, _ = channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{uint32(status)}))
+		return
+	}
+}
+
+func TestScanHostKeys(t *testing.T) {
+	addr, fingerprint, stop := startTestSSHServer(t, nil)
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+
+	cfg := &sshConfig{
+		User:   "test",
+		Server: host,
+		Port:   port,
+	}
+	captured, _, err := scanHostKeys(cfg, "", "", "")
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, fingerprint, captured)
+
+	// Pinned key matches
+	_, _, err = scanHostKeys(cfg, fingerprint, "", "")
+	assert.Nil(t, err)
+
+	// Pinned key does not match
+	_, _, err = scanHostKeys(cfg, "SHA256:doesnotmatch", "", "")
+	if assert.NotNil(t, err) {
+		assert.True(t, isHostKeyError(err))
+	}
+}
+
+func TestUnpinnedHostKeys(t *testing.T) {
+	addr, fingerprint, stop := startTestSSHServer(t, nil)
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+
+	// Without a pinned key no connection is made outside of a scan
+	cfg := &sshConfig{User: "test", Server: host, Port: port}
+	_, _, _, err := cfg.Run("echo")
+	if assert.NotNil(t, err) {
+		assert.True(t, isHostKeyError(err))
+	}
+
+	d := resourceContainerHostExec().TestResourceData()
+	err = applyHostKeys(cfg, d)
+	assert.NotNil(t, err)
+
+	_ = d.Set(hostKeyField, fingerprint)
+	if !assert.Nil(t, applyHostKeys(cfg, d)) {
+		return
+	}
+	stdout, _, _, err := cfg.Run("echo")
+	assert.Nil(t, err)
+	assert.Equal(t, "out: echo\n", stdout)
+
+	// A bastion needs its own pinned key
+	cfg.Bastion.Server = host
+	assert.NotNil(t, applyHostKeys(cfg, d))
+}
@@ -0,0 +1,107 @@
+package hsdp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// sshPool keeps one connection per host and bastion pair for the lifetime of the provider
+// process. Commands and uploads open their own session on the shared connection
+type sshPool struct {
+	mu      sync.Mutex
+	entries map[string]*sshPoolEntry
+}
+
+type sshPoolEntry struct {
+	mu       sync.Mutex
+	client   *ssh.Client
+	closeAll func()
+}
+
+var connectionPool = &sshPool{entries: make(map[string]*sshPoolEntry)}
+
+// poolKey identifies the connection. Credentials and pinned host keys are part of the key,
+// so a changed configuration never reuses a connection made with the old one
+func (c *sshConfig) poolKey() string {
+	h := sha256.New()
+	for _, v := range []string{
+		c.User, c.Server, c.Port, c.Key, c.Certificate, strconv.FormatBool(c.Agent), c.Fingerprint,
+		c.Bastion.User, c.Bastion.Server, c.Bastion.Port, c.Bastion.Key, c.Bastion.Certificate,
+		strconv.FormatBool(c.Bastion.Agent), c.Bastion.Fingerprint,
+	} {
+		_, _ = fmt.Fprintf(h, "%d:%s", len(v), v)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func (p *sshPool) entry(key string) *sshPoolEntry {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e, ok := p.entries[key]
+	if !ok {
+		e = &sshPoolEntry{}
+		p.entries[key] = e
+	}
+	return e
+}
+
+// get returns a live connection for cfg, dialing a new one when there is none yet or the
+// previous one was closed, e.g. by the bastion after being idle
+func (p *sshPool) get(cfg *sshConfig) (*ssh.Client, error) {
+	e := p.entry(cfg.poolKey())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client != nil {
+		if _, _, err := e.client.SendRequest("keepalive@openssh.com", true, nil); err == nil {
+			return e.client, nil
+		}
+		e.closeAll()
+		e.client = nil
+	}
+	client, closeAll, err := cfg.dial(nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	e.client, e.closeAll = client, closeAll
+	return client, nil
+}
+
+// evict closes the connection, unless it was already replaced by a new one
+func (p *sshPool) evict(cfg *sshConfig, client *ssh.Client) {
+	e := p.entry(cfg.poolKey())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.client == client && client != nil {
+		e.closeAll()
+		e.client = nil
+	}
+}
+
+// newSession opens a session on the pooled connection. A connection which fails to open
+// a channel is replaced once, unless the server refused the channel, e.g. because of its
+// MaxSessions limit
+func (c *sshConfig) newSession() (*ssh.Session, error) {
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		var client *ssh.Client
+		client, err = connectionPool.get(c)
+		if err != nil {
+			return nil, err
+		}
+		var session *ssh.Session
+		session, err = client.NewSession()
+		if err == nil {
+			return session, nil
+		}
+		if _, refused := err.(*ssh.OpenChannelError); refused {
+			return nil, err
+		}
+		connectionPool.evict(c, client)
+	}
+	return nil, err
+}
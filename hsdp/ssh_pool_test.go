@@ -0,0 +1,47 @@
+package hsdp
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectionPool(t *testing.T) {
+	addr, fingerprint, stop := startTestSSHServer(t, nil)
+	defer stop()
+	host, port, _ := net.SplitHostPort(addr)
+	cfg := &sshConfig{User: "test", Server: host, Port: port, Fingerprint: fingerprint}
+
+	first, err := connectionPool.get(cfg)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		stdout, _, done, err := cfg.Run("echo")
+		assert.Nil(t, err)
+		assert.True(t, done)
+		assert.Equal(t, "out: echo\n", stdout)
+	}
+	second, err := connectionPool.get(cfg)
+	if assert.Nil(t, err) {
+		assert.Same(t, first, second)
+	}
+
+	// A closed connection is replaced
+	_ = first.Close()
+	third, err := connectionPool.get(cfg)
+	if assert.Nil(t, err) {
+		assert.NotSame(t, first, third)
+	}
+
+	// Other credentials get their own connection
+	other := *cfg
+	other.User = "other"
+	fourth, err := connectionPool.get(&other)
+	if assert.Nil(t, err) {
+		assert.NotSame(t, third, fourth)
+	}
+	connectionPool.evict(cfg, third)
+	connectionPool.evict(&other, fourth)
+}
@@ -5,6 +5,8 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/backoff/v4"
@@ -85,3 +87,62 @@ func sshAgentReachable() bool {
 	defer conn.Close()
 	return true
 }
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min3(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
+
+func min3(a, b, c int) int {
+	m := a
+	if b < m {
+		m = b
+	}
+	if c < m {
+		m = c
+	}
+	return m
+}
+
+// nearMatches returns up to max candidates which are close to needle, closest first
+func nearMatches(needle string, candidates []string, max int) []string {
+	threshold := len(needle) / 3
+	if threshold < 2 {
+		threshold = 2
+	}
+	type match struct {
+		value    string
+		distance int
+	}
+	var matches []match
+	for _, c := range candidates {
+		if dist := levenshtein(strings.ToUpper(needle), strings.ToUpper(c)); dist <= threshold {
+			matches = append(matches, match{c, dist})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+	var result []string
+	for i := 0; i < len(matches) && i < max; i++ {
+		result = append(result, matches[i].value)
+	}
+	return result
+}
@@ -23,3 +23,12 @@ func TestSlidingExpiresOn(t *testing.T) {
 	sliding := slidingExpiresOn(now)
 	assert.Equal(t, expected, sliding)
 }
+
+func TestNearMatches(t *testing.T) {
+	assert.Equal(t, 1, levenshtein("GROUP.REED", "GROUP.READ"))
+	assert.Equal(t, 0, levenshtein("", ""))
+
+	catalog := []string{"GROUP.READ", "GROUP.WRITE", "USER.READ", "ROLE.READ"}
+	assert.Equal(t, []string{"GROUP.READ"}, nearMatches("GROUP.REED", catalog, 3))
+	assert.Empty(t, nearMatches("PKI_CERT.WRITE", catalog, 3))
+}
@@ -3,6 +3,7 @@ package hsdp
 import (
 	"encoding/json"
 	"fmt"
+	"regexp"
 	"strings"
 
 	"github.com/hashicorp/go-cty/cty"
@@ -35,6 +36,22 @@ func validateUpperString(val interface{}, key string) (warns []string, errs []er
 	return
 }
 
+// warnUnknownValue returns a validator which warns, instead of failing, when the value is not
+// one of the known values. Use it where the API may accept values the provider does not know yet
+func warnUnknownValue(known []string) schema.SchemaValidateFunc {
+	return func(val interface{}, key string) (warns []string, errs []error) {
+		v, ok := val.(string)
+		if !ok {
+			errs = append(errs, fmt.Errorf("expected type of %q to be string", key))
+			return
+		}
+		if !containsString(known, v) {
+			warns = append(warns, fmt.Sprintf("%q value %q is not one of the known values [%s]", key, v, strings.Join(known, ", ")))
+		}
+		return
+	}
+}
+
 func validatePolicyJSON(val interface{}, key string) (warns []string, errs []error) {
 	v := val.(string)
 	var policy creds.Policy
@@ -57,3 +74,37 @@ var thresholdMapping = map[string]threshold{
 	"http-rate":    {"threshold_http_rate", thresholdHTTPRateSchema},
 	"http-latency": {"threshold_http_latency", thresholdHTTPLatencySchema},
 }
+
+var placeholderRegexp = regexp.MustCompile(`{{\s*([^{}\s]*)\s*}}`)
+
+// findPlaceholders returns the unique placeholders used in message, in order of appearance
+func findPlaceholders(message string) []string {
+	var found []string
+	seen := map[string]bool{}
+	for _, match := range placeholderRegexp.FindAllStringSubmatch(message, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			found = append(found, match[1])
+		}
+	}
+	return found
+}
+
+// validatePlaceholders checks that message only uses placeholders from allowed
+func validatePlaceholders(message string, allowed []string) error {
+	supported := map[string]bool{}
+	for _, a := range allowed {
+		supported[a] = true
+	}
+	var unknown []string
+	for _, p := range findPlaceholders(message) {
+		if !supported[p] {
+			unknown = append(unknown, "{{"+p+"}}")
+		}
+	}
+	if len(unknown) > 0 {
+		return fmt.Errorf("unsupported placeholder(s) %s, supported are: {{%s}}",
+			strings.Join(unknown, ", "), strings.Join(allowed, "}}, {{"))
+	}
+	return nil
+}
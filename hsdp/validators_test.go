@@ -0,0 +1,79 @@
+package hsdp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/terraform-plugin-sdk/v2/terraform"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPlaceholders(t *testing.T) {
+	found := findPlaceholders("Code {{template.otp}} valid for {{ template.expiryPeriod }}, again: {{template.otp}}")
+	assert.Equal(t, []string{"template.otp", "template.expiryPeriod"}, found)
+	assert.Nil(t, findPlaceholders("no placeholders here"))
+}
+
+func TestValidatePlaceholders(t *testing.T) {
+	allowed := []string{"template.otp", "template.expiryPeriod"}
+
+	assert.Nil(t, validatePlaceholders("Your code is {{template.otp}}", allowed))
+	err := validatePlaceholders("Hi {{user.givenName}}, your code is {{template.otp}}", allowed)
+	if assert.NotNil(t, err) {
+		assert.Contains(t, err.Error(), "{{user.givenName}}")
+	}
+}
+
+func TestValidateEmailTemplate(t *testing.T) {
+	assert.Nil(t, validateEmailTemplate("PASSWORD_EXPIRY", "HTML", "Expiring",
+		"<p>Dear {{user.givenName}}, <a href=\"{{link.passwordChange}}\">change</a><br></p>"))
+
+	err := validateEmailTemplate("PASSWORD_CHANGED", "HTML", "Changed", "<p>{{link.passwordChange}}</p>")
+	assert.NotNil(t, err)
+
+	// HTML5 allows leaving out end tags
+	assert.Nil(t, validateEmailTemplate("PASSWORD_CHANGED", "HTML", "Changed", "<p>a<p>b<ul><li>one<li>two</ul>"))
+	// Types unknown to the provider only get the generic checks
+	assert.Nil(t, validateEmailTemplate("NEW_TYPE", "TEXT", "New", "{{link.somewhere}} <p>"))
+}
+
+func TestValidateEmailTemplateDiffSkipsUnchanged(t *testing.T) {
+	r := resourceIAMEmailTemplate()
+	raw := map[string]interface{}{
+		"managing_organization": "org",
+		"type":                  "PASSWORD_CHANGED",
+		"subject":               "Changed",
+		"message":               "<p>{{link.passwordChange}}</p>",
+	}
+	state := &terraform.InstanceState{ID: "template", Attributes: map[string]string{
+		"id":                    "template",
+		"managing_organization": "org",
+		"type":                  "PASSWORD_CHANGED",
+		"format":                "HTML",
+		"subject":               "Changed",
+		"message":               "<p>{{link.passwordChange}}</p>",
+	}}
+	_, err := r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	assert.Nil(t, err)
+
+	raw["subject"] = "Password changed"
+	_, err = r.Diff(context.Background(), state, terraform.NewResourceConfigRaw(raw), nil)
+	assert.NotNil(t, err)
+	_, err = r.Diff(context.Background(), nil, terraform.NewResourceConfigRaw(raw), nil)
+	assert.NotNil(t, err)
+}
+
+func TestWarnUnknownValue(t *testing.T) {
+	validate := warnUnknownValue([]string{"HTML"})
+	warns, errs := validate("HTML", "format")
+	assert.Empty(t, warns)
+	assert.Empty(t, errs)
+	warns, errs = validate("TEXT", "format")
+	assert.Len(t, warns, 1)
+	assert.Empty(t, errs)
+}
+
+func TestRenderEmailTemplate(t *testing.T) {
+	rendered := renderEmailTemplate("Hi {{ user.givenName }}, {{unknown}}", map[string]string{"user.givenName": "Jane"})
+	assert.Equal(t, "Hi Jane, {{unknown}}", rendered)
+}